@@ -0,0 +1,493 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+)
+
+type Cacher[K comparable, V any] interface {
+	Get(key K) (value V, err error)
+	Put(key K, value V) (err error)
+	Delete(key K) (ok bool)
+	Len() int
+	Weight() int64
+	Stats() Stats
+	All() iter.Seq2[K, V]
+	Keys() iter.Seq[K]
+	Values() iter.Seq[V]
+}
+
+// Cache is the concrete cache behind Cacher
+//
+// All methods are safe for concurrent use. Capacity is measured in weight
+// units; without a weigher every entry weighs 1, so capacity is simply the
+// number of entries. Which entry is evicted when the cache is full is up to
+// the Policy, LRU by default.
+//
+// Expired entries are removed lazily: when they are next read, when the
+// policy picks them for eviction, or by PurgeExpired. Until then they still
+// count towards Len and Weight.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int64
+	weight   int64 // total weight of all entries
+	cache    map[K]*entry[K, V]
+	queue    *list.List // recency order, most recently used at the front
+	seq      uint64     // last recency stamp handed out
+	policy   evictor[K, V]
+
+	ttl      time.Duration // default for Put, 0 means entries never expire
+	clock    Clock
+	weigher  Weigher[K, V]
+	onRemove RemovalFunc[K, V]
+
+	neg *Cache[K, error] // cached misses and load errors, if enabled
+
+	tags     map[string]map[*entry[K, V]]struct{} // entries by tag
+	prefixes *trie[K, V]                          // entries by key, for string keys with WithPrefixIndex
+
+	pinnedCount  int   // entries taken out of the policy by Pin
+	pinnedWeight int64 // their total weight
+
+	stats statsCounter
+}
+
+// entry is what the cache stores for each key
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	weight  int64
+	written time.Time
+	expires time.Time     // zero if the entry never expires
+	elem    *list.Element // position in Cache.queue, nil once removed
+	seq     uint64        // recency stamp; queue is sorted by it, highest first
+
+	// Bookkeeping owned by the eviction policy
+	pelem *list.Element // LRU and FIFO
+	freq  uint64        // LFU
+	tick  uint64        // LFU
+	index int           // LFU
+
+	tags   []string // see PutWithTags
+	pinned bool     // see Pin; a pinned entry is not known to the policy
+}
+
+// New builds a cache from options. WithCapacity is required; everything
+// else has a default. An invalid combination of options is reported as an
+// error wrapping ErrInvalidOption.
+func New[K comparable, V any](opts ...Option) (*Cache[K, V], error) {
+	o := options{policy: LRU, clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.capacity <= 0 {
+		return nil, fmt.Errorf("%w: capacity must be positive, got %d", ErrInvalidOption, o.capacity)
+	}
+	if o.ttl < 0 {
+		return nil, fmt.Errorf("%w: TTL must not be negative, got %v", ErrInvalidOption, o.ttl)
+	}
+	if o.clock == nil {
+		return nil, fmt.Errorf("%w: clock must not be nil", ErrInvalidOption)
+	}
+	policy, err := newEvictor[K, V](o.policy)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache[K, V]{
+		capacity: o.capacity,
+		cache:    make(map[K]*entry[K, V]),
+		queue:    list.New(),
+		policy:   policy,
+		ttl:      o.ttl,
+		clock:    o.clock,
+	}
+	if o.weigher != nil {
+		fn, ok := o.weigher.(Weigher[K, V])
+		if !ok {
+			return nil, fmt.Errorf("%w: weigher %T does not match the cache's key and value types", ErrInvalidOption, o.weigher)
+		}
+		c.weigher = fn
+	}
+	if o.onRemove != nil {
+		fn, ok := o.onRemove.(RemovalFunc[K, V])
+		if !ok {
+			return nil, fmt.Errorf("%w: removal callback %T does not match the cache's key and value types", ErrInvalidOption, o.onRemove)
+		}
+		c.onRemove = fn
+	}
+	if o.negative {
+		if o.negCapacity <= 0 || o.negTTL <= 0 {
+			return nil, fmt.Errorf("%w: negative cache needs a positive capacity and TTL, got %d and %v", ErrInvalidOption, o.negCapacity, o.negTTL)
+		}
+		c.neg, _ = New[K, error](WithCapacity(o.negCapacity), WithTTL(o.negTTL), WithClock(o.clock))
+	}
+	if o.prefixIndex {
+		var key K
+		if _, ok := any(key).(string); !ok {
+			return nil, fmt.Errorf("%w: a prefix index needs string keys, not %T", ErrInvalidOption, key)
+		}
+		c.prefixes = &trie[K, V]{}
+	}
+	return c, nil
+}
+
+// Constructor. size is the capacity: a number of entries, or a total weight
+// budget when WithWeigher is given. It panics if the options are invalid;
+// use New to get an error instead.
+func NewCacher[K comparable, V any](size int, opts ...Option) Cacher[K, V] {
+	c, err := New[K, V](append([]Option{WithCapacity(int64(size))}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Get method retrieves a value for a given key and marks it as used.
+// It returns ErrNotFound if the key is missing or has expired, or a
+// *NegativeError if a miss for the key was cached with PutNegative.
+func (c *Cache[K, V]) Get(key K) (value V, err error) {
+	value, _, _, err = c.lookup(key, 0)
+	return value, err
+}
+
+// lookup is Get for wrappers that need to know how old an entry is. It
+// returns the time since the entry was written, and keeps entries that
+// expired less than grace ago, returning them with stale set.
+func (c *Cache[K, V]) lookup(key K, grace time.Duration) (value V, age time.Duration, stale bool, err error) {
+	c.mu.Lock()
+	now := c.clock.Now()
+	e, ok := c.cache[key]
+	if ok && e.expiredAt(now) && !now.Before(e.expires.Add(grace)) {
+		c.removeEntry(e)
+		c.mu.Unlock()
+		c.notify([]removal[K, V]{{e.key, e.value, ReasonExpired}})
+		return value, 0, false, c.miss(key)
+	}
+	if !ok {
+		c.mu.Unlock()
+		return value, 0, false, c.miss(key)
+	}
+
+	c.markUsed(e)
+	if !e.pinned {
+		c.policy.touch(e)
+	}
+	value, age, stale = e.value, now.Sub(e.written), e.expiredAt(now)
+	c.mu.Unlock()
+
+	c.stats.hits.Add(1)
+	return value, age, stale, nil
+}
+
+// miss returns the error for a key that has no value: the cached negative
+// result if there is one, otherwise ErrNotFound, which counts as a miss.
+// It must be called without holding c.mu.
+func (c *Cache[K, V]) miss(key K) error {
+	if c.neg != nil {
+		if cause, err := c.neg.Get(key); err == nil {
+			return &NegativeError{Err: cause}
+		}
+	}
+	c.stats.misses.Add(1)
+	return ErrNotFound
+}
+
+// Put method adds a new key-value pair to the cache or updates an existing
+// key, using the cache's default TTL. Entries chosen by the policy are
+// evicted until the new entry fits. An entry heavier than the whole capacity
+// is rejected with ErrTooHeavy and the cache is left unchanged.
+//
+// Replacing a key reports the old value to the removal callback with
+// ReasonReplaced, even if the new value is the same.
+func (c *Cache[K, V]) Put(key K, value V) (err error) {
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL is Put with a TTL for this entry only. A ttl of 0 means the
+// entry never expires.
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) error {
+	return c.put(key, value, ttl, nil)
+}
+
+func (c *Cache[K, V]) put(key K, value V, ttl time.Duration, tags []string) error {
+	if ttl < 0 {
+		return fmt.Errorf("%w: TTL must not be negative, got %v", ErrInvalidOption, ttl)
+	}
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return err
+	}
+
+	var removed []removal[K, V]
+
+	c.mu.Lock()
+	now := c.clock.Now()
+	e, exists := c.cache[key]
+
+	// Pinned entries cannot make room, so check they leave enough of it
+	// before changing anything
+	pinned := c.pinnedWeight
+	if exists && e.pinned {
+		pinned -= e.weight
+	}
+	if pinned+w > c.capacity {
+		c.mu.Unlock()
+		return fmt.Errorf("%w: weight %d, capacity %d, pinned %d", ErrFull, w, c.capacity, pinned)
+	}
+
+	if exists {
+		// Update the existing key in place
+		removed = append(removed, removal[K, V]{e.key, e.value, ReasonReplaced})
+		c.weight += w - e.weight
+		if e.pinned {
+			c.pinnedWeight += w - e.weight
+		}
+		e.value, e.weight = value, w
+		c.markUsed(e)
+		if !e.pinned {
+			c.policy.touch(e)
+		}
+	} else {
+		e = &entry[K, V]{key: key, value: value, weight: w}
+		e.elem = c.queue.PushFront(e)
+		c.seq++
+		e.seq = c.seq
+		c.cache[key] = e
+		c.policy.add(e)
+		c.indexKey(e)
+		c.weight += w
+	}
+	if len(tags) > 0 || len(e.tags) > 0 {
+		c.setTags(e, tags)
+	}
+	e.written, e.expires = now, time.Time{}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+
+	// Evict until the cache is back under budget. The new entry fits next
+	// to the pinned ones, so the policy always has another victim to offer.
+	for c.weight > c.capacity {
+		victim := c.policy.victim(e)
+		c.removeEntry(victim)
+		reason := ReasonCapacity
+		if victim.expiredAt(now) {
+			reason = ReasonExpired
+		}
+		removed = append(removed, removal[K, V]{victim.key, victim.value, reason})
+	}
+	c.mu.Unlock()
+
+	if c.neg != nil {
+		c.neg.Delete(key)
+	}
+	c.stats.puts.Add(1)
+	c.notify(removed)
+	return nil
+}
+
+// PutNegative remembers that key has no value, so Gets return a
+// *NegativeError wrapping err instead of ErrNotFound until the negative
+// TTL runs out. A nil err is stored as ErrNotFound. Any value cached for
+// the key is dropped and reported with ReasonReplaced. It returns
+// ErrInvalidOption if the cache was built without WithNegativeCache.
+func (c *Cache[K, V]) PutNegative(key K, err error) error {
+	if c.neg == nil {
+		return fmt.Errorf("%w: negative caching is not enabled", ErrInvalidOption)
+	}
+	if err == nil {
+		err = ErrNotFound
+	}
+
+	c.mu.Lock()
+	e, ok := c.cache[key]
+	if ok {
+		c.removeEntry(e)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.notify([]removal[K, V]{{e.key, e.value, ReasonReplaced}})
+	}
+	return c.neg.Put(key, err)
+}
+
+// Delete removes a key from the cache, along with any cached negative
+// result for it, and reports whether either was present
+func (c *Cache[K, V]) Delete(key K) (ok bool) {
+	c.mu.Lock()
+	e, ok := c.cache[key]
+	if ok {
+		c.removeEntry(e)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.notify([]removal[K, V]{{e.key, e.value, ReasonDeleted}})
+	}
+	if c.neg != nil && c.neg.Delete(key) {
+		ok = true
+	}
+	return ok
+}
+
+// Contains reports whether key is in the cache and unexpired, without
+// marking it as used or counting a hit or miss
+func (c *Cache[K, V]) Contains(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	return ok && !e.expiredAt(c.clock.Now())
+}
+
+// TTL returns how long key has left to live. It returns 0 for an entry that
+// never expires, and false if the key is missing or expired.
+func (c *Cache[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	now := c.clock.Now()
+	if !ok || e.expiredAt(now) {
+		return 0, false
+	}
+	if e.expires.IsZero() {
+		return 0, true
+	}
+	return e.expires.Sub(now), true
+}
+
+// Clear removes every entry, reporting each to the removal callback with
+// ReasonDeleted, and forgets every cached negative result
+func (c *Cache[K, V]) Clear() {
+	if c.neg != nil {
+		c.neg.Clear()
+	}
+
+	var removed []removal[K, V]
+
+	c.mu.Lock()
+	for _, e := range c.cache {
+		c.removeEntry(e)
+		removed = append(removed, removal[K, V]{e.key, e.value, ReasonDeleted})
+	}
+	c.mu.Unlock()
+
+	c.notify(removed)
+}
+
+// PurgeExpired removes every expired entry now and returns how many were
+// removed. It looks at every entry, so call it periodically rather than on
+// every request.
+func (c *Cache[K, V]) PurgeExpired() int {
+	var removed []removal[K, V]
+
+	c.mu.Lock()
+	now := c.clock.Now()
+	for _, e := range c.cache {
+		if e.expiredAt(now) {
+			c.removeEntry(e)
+			removed = append(removed, removal[K, V]{e.key, e.value, ReasonExpired})
+		}
+	}
+	c.mu.Unlock()
+
+	c.notify(removed)
+	return len(removed)
+}
+
+// Len returns the number of entries in the cache
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cache)
+}
+
+// Weight returns the total weight of all entries in the cache
+func (c *Cache[K, V]) Weight() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.weight
+}
+
+// Capacity returns the cache's weight budget
+func (c *Cache[K, V]) Capacity() int64 {
+	return c.capacity
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *Cache[K, V]) Stats() Stats {
+	s := c.stats.snapshot()
+	c.mu.Lock()
+	s.Entries, s.Weight = len(c.cache), c.weight
+	s.PinnedEntries, s.PinnedWeight = c.pinnedCount, c.pinnedWeight
+	c.mu.Unlock()
+	if c.neg != nil {
+		ns := c.neg.Stats()
+		s.NegativeHits, s.NegativeEntries = ns.Hits, ns.Entries
+	}
+	return s
+}
+
+// weigh returns the weight of an entry, checking it against the capacity
+func (c *Cache[K, V]) weigh(key K, value V) (int64, error) {
+	if c.weigher == nil {
+		return 1, nil
+	}
+	w := c.weigher(key, value)
+	if w < 0 {
+		return 0, fmt.Errorf("%w: weigher returned %d for key %v", ErrInvalidWeight, w, key)
+	}
+	if w > c.capacity {
+		return 0, fmt.Errorf("%w: weight %d, capacity %d", ErrTooHeavy, w, c.capacity)
+	}
+	return w, nil
+}
+
+// markUsed moves an entry to the front of the recency queue.
+// The caller must hold c.mu.
+func (c *Cache[K, V]) markUsed(e *entry[K, V]) {
+	c.queue.MoveToFront(e.elem)
+	c.seq++
+	e.seq = c.seq
+}
+
+// removeEntry unlinks an entry from the map, the queue and the policy.
+// The caller must hold c.mu.
+func (c *Cache[K, V]) removeEntry(e *entry[K, V]) {
+	c.queue.Remove(e.elem)
+	e.elem = nil
+	if e.pinned {
+		e.pinned = false
+		c.pinnedCount--
+		c.pinnedWeight -= e.weight
+	} else {
+		c.policy.remove(e)
+	}
+	c.unindex(e)
+	delete(c.cache, e.key)
+	c.weight -= e.weight
+}
+
+// notify runs the removal callback for entries that have already been
+// unlinked. It must be called without holding c.mu.
+func (c *Cache[K, V]) notify(removed []removal[K, V]) {
+	for _, r := range removed {
+		c.stats.removals[r.reason].Add(1)
+	}
+	if c.onRemove == nil {
+		return
+	}
+	for _, r := range removed {
+		c.onRemove(r.key, r.value, r.reason)
+	}
+}
+
+// expiredAt reports whether the entry's TTL has run out at now
+func (e *entry[K, V]) expiredAt(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
@@ -0,0 +1,98 @@
+// Package cacheprom exports cache statistics as Prometheus metrics.
+// It lives in its own package so the cache package itself stays free of
+// the Prometheus dependency.
+package cacheprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	cache "iru.com"
+)
+
+// StatsSource is anything that can report cache statistics, such as a
+// cache.Cacher
+type StatsSource interface {
+	Stats() cache.Stats
+}
+
+// Collector is a prometheus.Collector that reads a cache's Stats on every
+// scrape. All metrics carry a "cache" label with the name it was built with.
+type Collector struct {
+	src StatsSource
+
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	puts     *prometheus.Desc
+	removals *prometheus.Desc
+	loads    *prometheus.Desc
+	loadTime *prometheus.Desc
+	entries  *prometheus.Desc
+	weight   *prometheus.Desc
+
+	negativeHits    *prometheus.Desc
+	negativeEntries *prometheus.Desc
+	pinnedEntries   *prometheus.Desc
+	pinnedWeight    *prometheus.Desc
+}
+
+// NewCollector returns a collector for src. Register it with
+// prometheus.MustRegister, once per cache name.
+func NewCollector(name string, src StatsSource) *Collector {
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string, variable ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("cache", "", metric), help, variable, labels)
+	}
+	return &Collector{
+		src:      src,
+		hits:     desc("hits_total", "Number of Get calls that found the key."),
+		misses:   desc("misses_total", "Number of Get calls that did not find the key."),
+		puts:     desc("puts_total", "Number of successful Put calls."),
+		removals: desc("removals_total", "Number of entries that left the cache, by reason.", "reason"),
+		loads:    desc("loads_total", "Number of loader calls, by result.", "result"),
+		loadTime: desc("load_duration_seconds", "Time spent in loader calls."),
+		entries:  desc("entries", "Number of entries in the cache."),
+		weight:   desc("weight", "Total weight of the entries in the cache."),
+
+		negativeHits:    desc("negative_hits_total", "Number of Get calls answered by a cached negative result."),
+		negativeEntries: desc("negative_entries", "Number of cached negative results."),
+		pinnedEntries:   desc("pinned_entries", "Number of entries pinned against eviction."),
+		pinnedWeight:    desc("pinned_weight", "Total weight of the pinned entries."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.puts
+	ch <- c.removals
+	ch <- c.loads
+	ch <- c.loadTime
+	ch <- c.entries
+	ch <- c.weight
+	ch <- c.negativeHits
+	ch <- c.negativeEntries
+	ch <- c.pinnedEntries
+	ch <- c.pinnedWeight
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.src.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.puts, prometheus.CounterValue, float64(s.Puts))
+	for r, n := range s.Removals {
+		ch <- prometheus.MustNewConstMetric(c.removals, prometheus.CounterValue, float64(n), cache.RemovalReason(r).String())
+	}
+	ch <- prometheus.MustNewConstMetric(c.loads, prometheus.CounterValue, float64(s.LoadSuccesses), "success")
+	ch <- prometheus.MustNewConstMetric(c.loads, prometheus.CounterValue, float64(s.LoadErrors), "error")
+	ch <- prometheus.MustNewConstSummary(c.loadTime, s.Loads(), s.LoadTime.Seconds(), nil)
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(c.weight, prometheus.GaugeValue, float64(s.Weight))
+	ch <- prometheus.MustNewConstMetric(c.negativeHits, prometheus.CounterValue, float64(s.NegativeHits))
+	ch <- prometheus.MustNewConstMetric(c.negativeEntries, prometheus.GaugeValue, float64(s.NegativeEntries))
+	ch <- prometheus.MustNewConstMetric(c.pinnedEntries, prometheus.GaugeValue, float64(s.PinnedEntries))
+	ch <- prometheus.MustNewConstMetric(c.pinnedWeight, prometheus.GaugeValue, float64(s.PinnedWeight))
+}
@@ -0,0 +1,38 @@
+package cacheprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	cache "iru.com"
+)
+
+func TestCollector(t *testing.T) {
+	c := cache.NewCacher[string, string](1)
+	c.Put("key1", "val1")
+	c.Put("key2", "val2") // evicts key1
+	c.Get("key1")
+	c.Get("key2")
+
+	want := `
+# HELP cache_hits_total Number of Get calls that found the key.
+# TYPE cache_hits_total counter
+cache_hits_total{cache="test"} 1
+# HELP cache_misses_total Number of Get calls that did not find the key.
+# TYPE cache_misses_total counter
+cache_misses_total{cache="test"} 1
+# HELP cache_removals_total Number of entries that left the cache, by reason.
+# TYPE cache_removals_total counter
+cache_removals_total{cache="test",reason="capacity"} 1
+cache_removals_total{cache="test",reason="deleted"} 0
+cache_removals_total{cache="test",reason="expired"} 0
+cache_removals_total{cache="test",reason="replaced"} 0
+`
+	err := testutil.CollectAndCompare(NewCollector("test", c), strings.NewReader(want),
+		"cache_hits_total", "cache_misses_total", "cache_removals_total")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,13 @@
+package cache
+
+import "time"
+
+// Clock tells the cache what time it is
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
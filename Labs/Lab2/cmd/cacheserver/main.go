@@ -0,0 +1,94 @@
+// Cacheserver serves an in-memory cache to Redis clients:
+//
+//	cacheserver -addr :6379 -capacity 268435456 &
+//	redis-cli set greeting hello ex 60
+//	redis-cli get greeting
+//
+// With -snapshot it restores the cache from that file on startup and
+// saves it periodically and on shutdown.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	cache "iru.com"
+	"iru.com/resp"
+)
+
+func main() {
+	addr := flag.String("addr", ":6379", "address to listen on")
+	capacity := flag.Int64("capacity", 256<<20, "cache capacity in bytes of keys and values")
+	policy := flag.String("policy", "LRU", "eviction policy: LRU, FIFO or LFU")
+	maxConns := flag.Int("maxclients", 10000, "maximum concurrent connections")
+	idle := flag.Duration("timeout", 0, "close connections idle this long (0 = never)")
+	snapshot := flag.String("snapshot", "", "snapshot file to restore from and save to")
+	every := flag.Duration("snapshot-interval", time.Minute, "how often to save the snapshot")
+	flag.Parse()
+
+	if *every <= 0 {
+		log.Fatal("-snapshot-interval must be positive")
+	}
+
+	var pol cache.Policy = -1
+	for _, p := range cache.Policies {
+		if p.String() == *policy {
+			pol = p
+		}
+	}
+
+	c, err := cache.New[string, []byte](
+		cache.WithCapacity(*capacity),
+		cache.WithPolicy(pol),
+		cache.WithWeigher(func(key string, value []byte) int64 {
+			return int64(len(key) + len(value))
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stopSnapshots := func() error { return nil }
+	if *snapshot != "" {
+		n, err := c.LoadSnapshot(*snapshot, cache.GobCodec)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("no snapshot at %s, starting empty", *snapshot)
+		case err != nil:
+			log.Printf("restoring snapshot: %v (kept %d entries)", err, n)
+		default:
+			log.Printf("restored %d entries from %s", n, *snapshot)
+		}
+		stopSnapshots = c.StartSnapshots(*snapshot, cache.GobCodec, *every, func(err error) {
+			log.Print(err)
+		})
+	}
+
+	srv := resp.NewServer(c, resp.Options{MaxConns: *maxConns, IdleTimeout: *idle})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		log.Print("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("listening on %s", *addr)
+	if err := srv.ListenAndServe(*addr); !errors.Is(err, resp.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	if err := stopSnapshots(); err != nil {
+		log.Printf("final snapshot: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	cache "iru.com"
+)
+
+// bruteOptimal is Bélády's algorithm the slow way: on each eviction, scan
+// ahead for the cached key used furthest in the future
+func bruteOptimal(keys []int, capacity int) int {
+	var cached []int
+	hits := 0
+	for i, k := range keys {
+		if slices.Contains(cached, k) {
+			hits++
+			continue
+		}
+		if len(cached) == capacity {
+			victim, furthest := 0, -1
+			for j, c := range cached {
+				next := slices.Index(keys[i+1:], c)
+				if next < 0 {
+					next = len(keys)
+				}
+				if next > furthest {
+					victim, furthest = j, next
+				}
+			}
+			cached = slices.Delete(cached, victim, victim+1)
+		}
+		cached = append(cached, k)
+	}
+	return hits
+}
+
+func TestOptimalMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for round := range 3000 {
+		numKeys := 1 + rng.IntN(12)
+		keys := make([]int, rng.IntN(60))
+		for i := range keys {
+			// Skew towards low keys, as real traces are
+			keys[i] = min(rng.IntN(numKeys), rng.IntN(numKeys))
+		}
+		capacity := 1 + rng.IntN(numKeys)
+		if got, want := optimal(keys, numKeys, capacity), bruteOptimal(keys, capacity); got != want {
+			t.Fatalf("round %d: optimal(%v, capacity %d) = %d hits, want %d", round, keys, capacity, got, want)
+		}
+	}
+}
+
+func TestOptimalBeatsPolicies(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	var b strings.Builder
+	for range 5000 {
+		b.WriteString(string(rune('a'+min(rng.IntN(26), rng.IntN(26)))) + "\n")
+	}
+	tr, err := readTrace(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	capacities := []int64{1, 3, 8, 26}
+	results := simulate(tr, capacities, 0)
+	for i, capacity := range capacities {
+		for j, p := range cache.Policies {
+			if results[i][j] > results[i][optColumn]+1e-9 {
+				t.Errorf("capacity %d: %v hit %.4f, better than OPT's %.4f", capacity, p, results[i][j], results[i][optColumn])
+			}
+		}
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	tr, err := readTrace(strings.NewReader("# header\na\n\nb\n  a  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tr.keys, []int{0, 1, 0}) || !slices.Equal(tr.names, []string{"a", "b"}) || tr.times != nil {
+		t.Errorf("got keys %v, names %v, times %v", tr.keys, tr.names, tr.times)
+	}
+
+	tr, err = readTrace(strings.NewReader("1700000000.5 a\n2023-11-14T22:13:21Z b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{time.Unix(1700000000, 5e8), time.Unix(1700000001, 0)}
+	if len(tr.times) != 2 || !tr.times[0].Equal(want[0]) || !tr.times[1].Equal(want[1]) {
+		t.Errorf("times = %v, want %v", tr.times, want)
+	}
+}
+
+func TestReadTraceErrors(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"1 a\nb\n", "line 2: missing timestamp"},
+		{"a\n1 b\n", "line 2: unexpected timestamp"},
+		{"# c\nyesterday a\n", "line 2: bad timestamp"},
+		{"1 a b\n", "line 1: want"},
+	}
+	for _, tt := range tests {
+		_, err := readTrace(strings.NewReader(tt.in))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("readTrace(%q) = %v, want an error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestParseSizes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int64
+	}{
+		{"1000, 5", []int64{1000, 5}},
+		{"10%,25%", []int64{20, 50}},
+		{"0.1%", []int64{1}},  // rounds up to at least one entry
+		{"1.25%", []int64{3}}, // 2.5 rounds to 3
+		{"100%", []int64{200}},
+	}
+	for _, tt := range tests {
+		got, err := parseSizes(tt.in, 200)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("parseSizes(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0", "-5", "x%", "0%", "1.5"} {
+		if _, err := parseSizes(bad, 200); err == nil {
+			t.Errorf("parseSizes(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Cachesim replays a key-access trace against every eviction policy at a
+// range of cache sizes and reports the hit ratios, next to the optimum from
+// Bélády's algorithm:
+//
+//	cachesim -sizes 1%,5%,10%,25% access.log
+//	cachesim -csv -sizes 1000,10000,100000 access.log > ratios.csv
+//
+// The trace has one key per line, optionally preceded by a timestamp
+// (Unix seconds or RFC 3339). Timestamps drive the cache clock, so with
+// -ttl entries expire as they would have in production; OPT ignores the
+// TTL, so it is then only an upper bound. The trace is read from standard
+// input if no file is given.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	cache "iru.com"
+)
+
+func main() {
+	sizes := flag.String("sizes", "1%,2%,5%,10%,20%,50%", "comma-separated cache sizes, in entries or as a percentage of distinct keys")
+	ttl := flag.Duration("ttl", 0, "entry TTL; needs a timestamped trace (0 = never expire)")
+	asCSV := flag.Bool("csv", false, "write CSV instead of a table")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: cachesim [flags] [trace]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("cachesim: ")
+
+	in := io.Reader(os.Stdin)
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	t, err := readTrace(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(t.keys) == 0 {
+		log.Fatal("the trace is empty")
+	}
+	if *ttl != 0 && t.times == nil {
+		log.Fatal("-ttl needs a timestamped trace")
+	}
+	capacities, err := parseSizes(*sizes, len(t.names))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results := simulate(t, capacities, *ttl)
+	if *asCSV {
+		err = writeCSV(os.Stdout, capacities, results)
+	} else {
+		err = writeTable(os.Stdout, t, capacities, results)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseSizes turns "1000,5%" into entry counts; percentages are of the
+// number of distinct keys and round to at least one entry
+func parseSizes(s string, distinct int) ([]int64, error) {
+	var sizes []int64
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if pct, ok := strings.CutSuffix(f, "%"); ok {
+			p, err := strconv.ParseFloat(pct, 64)
+			if err != nil || p <= 0 {
+				return nil, fmt.Errorf("bad size %q", f)
+			}
+			sizes = append(sizes, max(int64(p/100*float64(distinct)+0.5), 1))
+			continue
+		}
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("bad size %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// optColumn is the results column after the policies
+var optColumn = len(cache.Policies)
+
+// simulate returns the hit ratio of every policy, then of the optimum, at
+// each capacity: results[capacity index][column]. Runs are independent, so
+// they go in parallel.
+func simulate(t *trace, capacities []int64, ttl time.Duration) [][]float64 {
+	results := make([][]float64, len(capacities))
+	var wg sync.WaitGroup
+	for i, capacity := range capacities {
+		results[i] = make([]float64, optColumn+1)
+		for j, p := range cache.Policies {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i][j] = replay(t, p, capacity, ttl)
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// A cache can never hold more entries than there are keys
+			n := int(min(capacity, int64(len(t.names))))
+			results[i][optColumn] = float64(optimal(t.keys, len(t.names), n)) / float64(len(t.keys))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// replay runs the trace through a cache, loading every miss into it, and
+// returns the hit ratio
+func replay(t *trace, p cache.Policy, capacity int64, ttl time.Duration) float64 {
+	clock := &traceClock{}
+	c, err := cache.New[int, struct{}](cache.WithCapacity(capacity), cache.WithPolicy(p),
+		cache.WithTTL(ttl), cache.WithClock(clock))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, k := range t.keys {
+		if t.times != nil {
+			clock.now = t.times[i]
+		}
+		if _, err := c.Get(k); err != nil {
+			c.Put(k, struct{}{})
+		}
+	}
+	return c.Stats().HitRatio()
+}
+
+// traceClock is the cache clock during a replay: the time of the access
+// being replayed
+type traceClock struct{ now time.Time }
+
+func (c *traceClock) Now() time.Time { return c.now }
+
+func writeTable(w io.Writer, t *trace, capacities []int64, results [][]float64) error {
+	fmt.Fprintf(w, "%d accesses, %d distinct keys\n\n", len(t.keys), len(t.names))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "capacity\t")
+	for _, p := range cache.Policies {
+		fmt.Fprintf(tw, "%v\t", p)
+	}
+	fmt.Fprint(tw, "OPT\t\n")
+	for i, capacity := range capacities {
+		fmt.Fprintf(tw, "%d\t", capacity)
+		for _, r := range results[i] {
+			fmt.Fprintf(tw, "%.2f%%\t", 100*r)
+		}
+		fmt.Fprint(tw, "\n")
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, capacities []int64, results [][]float64) error {
+	cw := csv.NewWriter(w)
+	header := []string{"capacity"}
+	for _, p := range cache.Policies {
+		header = append(header, p.String())
+	}
+	cw.Write(append(header, "OPT"))
+	for i, capacity := range capacities {
+		row := []string{strconv.FormatInt(capacity, 10)}
+		for _, r := range results[i] {
+			row = append(row, strconv.FormatFloat(r, 'f', 4, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package main
+
+import "container/heap"
+
+// optimal replays keys against Bélády's algorithm: on a miss with the cache
+// full, evict the key whose next use is furthest away. No policy that has
+// to insert every missed key can do better, so it bounds the real ones.
+// It returns the number of hits.
+func optimal(keys []int, numKeys int, capacity int) int {
+	// nextUse[i] is the position of the next access to keys[i]
+	never := len(keys)
+	nextUse := make([]int, len(keys))
+	last := make([]int, numKeys)
+	for k := range last {
+		last[k] = never
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		nextUse[i] = last[keys[i]]
+		last[keys[i]] = i
+	}
+
+	// The heap holds one item per access rather than per key; items made
+	// out of date by a later access to the key are skipped when popped
+	cached := make([]bool, numKeys)
+	due := make([]int, numKeys) // next use of each cached key
+	size, hits := 0, 0
+	var h furthest
+	for i, k := range keys {
+		if cached[k] {
+			hits++
+		} else {
+			if size == capacity {
+				for {
+					it := heap.Pop(&h).(useItem)
+					if cached[it.key] && due[it.key] == it.next {
+						cached[it.key] = false
+						break
+					}
+				}
+			} else {
+				size++
+			}
+			cached[k] = true
+		}
+		due[k] = nextUse[i]
+		heap.Push(&h, useItem{next: nextUse[i], key: k})
+	}
+	return hits
+}
+
+type useItem struct{ next, key int }
+
+// furthest is a max-heap of useItems by next use
+type furthest []useItem
+
+func (h furthest) Len() int           { return len(h) }
+func (h furthest) Less(i, j int) bool { return h[i].next > h[j].next }
+func (h furthest) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *furthest) Push(x any)        { *h = append(*h, x.(useItem)) }
+
+func (h *furthest) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
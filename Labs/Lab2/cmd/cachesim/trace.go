@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trace is a sequence of key accesses. Keys are numbered in order of first
+// appearance, so simulations can use ints instead of strings.
+type trace struct {
+	keys  []int       // key number of each access
+	times []time.Time // time of each access, nil for an untimed trace
+	names []string    // key names by number
+}
+
+// readTrace parses one access per line, either "key" or "timestamp key".
+// A timestamp is Unix seconds, possibly fractional, or RFC 3339. Blank
+// lines and lines starting with # are skipped. Either every line has a
+// timestamp or none does.
+func readTrace(r io.Reader) (*trace, error) {
+	t := &trace{}
+	ids := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		var key string
+		switch len(fields) {
+		case 1:
+			if t.times != nil {
+				return nil, fmt.Errorf("line %d: missing timestamp", line)
+			}
+			key = fields[0]
+		case 2:
+			if len(t.keys) > 0 && t.times == nil {
+				return nil, fmt.Errorf("line %d: unexpected timestamp in an untimed trace", line)
+			}
+			at, err := parseTime(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			t.times = append(t.times, at)
+			key = fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: want \"key\" or \"timestamp key\", got %q", line, text)
+		}
+
+		id, ok := ids[key]
+		if !ok {
+			id = len(t.names)
+			ids[key] = id
+			t.names = append(t.names, key)
+		}
+		t.keys = append(t.keys, id)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9)), nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad timestamp %q: want Unix seconds or RFC 3339", s)
+	}
+	return at, nil
+}
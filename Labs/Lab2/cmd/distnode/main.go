@@ -0,0 +1,88 @@
+// Distnode runs one node of a distcache cluster, for trying it out with a
+// few local processes:
+//
+//	distnode -addr :8001 -peers http://localhost:8001,http://localhost:8002 &
+//	distnode -addr :8002 -peers http://localhost:8001,http://localhost:8002 &
+//	curl 'localhost:8001/get?key=shoes'
+//
+// The backend is simulated: it is slow, and every value names the node that
+// loaded it, so it is easy to see that each key is loaded once. POST a new
+// comma-separated peer list to /peers to change the membership at runtime.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	cache "iru.com"
+	"iru.com/distcache"
+)
+
+func main() {
+	addr := flag.String("addr", ":8001", "address to listen on")
+	self := flag.String("self", "", "this node's base URL (default http://localhost<addr>)")
+	peers := flag.String("peers", "", "comma-separated base URLs of all nodes, including this one")
+	flag.Parse()
+
+	if *self == "" {
+		*self = "http://localhost" + *addr
+	}
+
+	pool := distcache.NewHTTPPool(*self, distcache.HTTPPoolOptions{})
+	group, err := pool.NewGroup("demo", 64<<20, func(ctx context.Context, key string) ([]byte, error) {
+		log.Printf("loading %q from the backend", key)
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if key == "missing" {
+			return nil, cache.ErrNotFound
+		}
+		return []byte(fmt.Sprintf("%s (loaded by %s)", key, *self)), nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *peers != "" {
+		pool.Set(strings.Split(*peers, ",")...)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(distcache.DefaultBasePath, pool)
+	mux.HandleFunc("/get", func(w http.ResponseWriter, req *http.Request) {
+		value, err := group.Get(req.Context(), req.URL.Query().Get("key"))
+		switch {
+		case errors.Is(err, cache.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			fmt.Fprintf(w, "%s\n", value)
+		}
+	})
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list := strings.Split(strings.TrimSpace(string(body)), ",")
+		pool.Set(list...)
+		fmt.Fprintf(w, "peers: %v\n", list)
+	})
+
+	log.Printf("node %s listening on %s", *self, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,112 @@
+package distcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cache "iru.com"
+)
+
+// hotTTL bounds how long a copy of another node's key is served without
+// asking the owner again
+const hotTTL = time.Minute
+
+// Getter loads a key from the backend. Only the key's owner calls it,
+// unless the owner cannot be reached. It should return an error wrapping
+// cache.ErrNotFound for keys the backend does not have.
+type Getter func(ctx context.Context, key string) ([]byte, error)
+
+// Peer fetches a group's key from another node
+type Peer interface {
+	Fetch(ctx context.Context, group, key string) ([]byte, error)
+}
+
+// PeerPicker finds the node that owns a key
+type PeerPicker interface {
+	// PickPeer returns the owner of key, or false if this node owns it
+	PickPeer(key string) (peer Peer, ok bool)
+}
+
+// noPeers is the PeerPicker of a group that runs on its own
+type noPeers struct{}
+
+func (noPeers) PickPeer(string) (Peer, bool) { return nil, false }
+
+// Group is a named, distributed namespace of keys with one Getter.
+// Values are shared between callers and must not be modified.
+type Group struct {
+	name   string
+	getter Getter
+	peers  PeerPicker
+
+	main *cache.LoadingCache[string, []byte] // keys this node owns
+	hot  *cache.LoadingCache[string, []byte] // copies of keys other nodes own
+}
+
+// NewGroup returns a group that keeps up to cacheBytes of keys and values
+// in memory: seven eighths for keys it owns and one eighth for hot copies
+// of other nodes' keys. A nil peers makes every key local.
+func NewGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) (*Group, error) {
+	if peers == nil {
+		peers = noPeers{}
+	}
+	hotBytes := max(cacheBytes/8, 1)
+	weigher := cache.WithWeigher(func(key string, value []byte) int64 {
+		return int64(len(key) + len(value))
+	})
+
+	main, err := cache.New[string, []byte](cache.WithCapacity(cacheBytes-hotBytes), weigher)
+	if err != nil {
+		return nil, fmt.Errorf("distcache: group %q: %w", name, err)
+	}
+	hot, err := cache.New[string, []byte](cache.WithCapacity(hotBytes), cache.WithTTL(hotTTL), weigher)
+	if err != nil {
+		return nil, fmt.Errorf("distcache: group %q: %w", name, err)
+	}
+
+	g := &Group{name: name, getter: getter, peers: peers}
+	g.main = cache.NewLoadingCache[string, []byte](main, cache.Loader[string, []byte](getter))
+	g.hot = cache.NewLoadingCache[string, []byte](hot, g.fetch)
+	return g, nil
+}
+
+// Name returns the group's name
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get returns the value for key from whichever node owns it. Concurrent
+// Gets of the same key on one node share a single fetch or load.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	if _, ok := g.peers.PickPeer(key); ok {
+		return g.hot.Get(ctx, key)
+	}
+	return g.main.Get(ctx, key)
+}
+
+// getLocal serves a key this node was asked for by a peer. It never
+// forwards the request, so peers with different views of the membership
+// cannot bounce a key between them.
+func (g *Group) getLocal(ctx context.Context, key string) ([]byte, error) {
+	return g.main.Get(ctx, key)
+}
+
+// fetch is the hot cache's loader. It asks the owner for the key, falling
+// back to the local Getter if the owner cannot be reached.
+func (g *Group) fetch(ctx context.Context, key string) ([]byte, error) {
+	if peer, ok := g.peers.PickPeer(key); ok {
+		v, err := peer.Fetch(ctx, g.name, key)
+		var remote *RemoteError
+		if err == nil || errors.As(err, &remote) || ctx.Err() != nil {
+			return v, err
+		}
+	}
+	return g.getter(ctx, key)
+}
+
+// Stats returns the statistics of the main and hot caches
+func (g *Group) Stats() (main, hot cache.Stats) {
+	return g.main.Stats(), g.hot.Stats()
+}
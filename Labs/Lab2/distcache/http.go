@@ -0,0 +1,188 @@
+package distcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	cache "iru.com"
+)
+
+// DefaultBasePath is where an HTTPPool serves peer requests by default
+const DefaultBasePath = "/_distcache/"
+
+// HTTPPoolOptions tunes an HTTPPool. Zero fields take defaults.
+type HTTPPoolOptions struct {
+	BasePath string       // URL path prefix for peer requests, default DefaultBasePath
+	Replicas int          // virtual nodes per peer on the ring, default 50
+	Client   *http.Client // used to fetch from peers, default http.DefaultClient
+}
+
+// HTTPPool is the set of peers a node shares its groups with, talking
+// HTTP. It is both the PeerPicker for its groups and the http.Handler that
+// answers other peers.
+type HTTPPool struct {
+	self string // this node's base URL, e.g. "http://10.0.0.1:8000"
+	opts HTTPPoolOptions
+
+	mu     sync.RWMutex
+	ring   *Ring
+	peers  map[string]*httpPeer // base URL -> peer, excluding self
+	groups map[string]*Group
+}
+
+// NewHTTPPool returns a pool for the node reachable at self. Until Set is
+// called the node owns every key.
+func NewHTTPPool(self string, opts HTTPPoolOptions) *HTTPPool {
+	if opts.BasePath == "" {
+		opts.BasePath = DefaultBasePath
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = 50
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &HTTPPool{
+		self:   self,
+		opts:   opts,
+		ring:   NewRing(opts.Replicas, nil),
+		peers:  make(map[string]*httpPeer),
+		groups: make(map[string]*Group),
+	}
+}
+
+// NewGroup creates a group shared through this pool. Every node must
+// create the same groups.
+func (p *HTTPPool) NewGroup(name string, cacheBytes int64, getter Getter) (*Group, error) {
+	g, err := NewGroup(name, cacheBytes, getter, p)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, dup := p.groups[name]; dup {
+		return nil, fmt.Errorf("distcache: group %q already exists", name)
+	}
+	p.groups[name] = g
+	return g, nil
+}
+
+// Set replaces the membership with peers, given as base URLs. It should
+// include this node's own URL, and may be called at any time; keys whose
+// owner changes are simply loaded again by their new owner.
+func (p *HTTPPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ring.Set(peers...)
+	p.peers = make(map[string]*httpPeer, len(peers))
+	for _, peer := range peers {
+		if peer != p.self {
+			p.peers[peer] = &httpPeer{baseURL: peer + p.opts.BasePath, client: p.opts.Client}
+		}
+	}
+}
+
+// PickPeer implements PeerPicker
+func (p *HTTPPool) PickPeer(key string) (Peer, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	owner := p.ring.Owner(key)
+	if owner == "" || owner == p.self {
+		return nil, false
+	}
+	return p.peers[owner], true
+}
+
+// ServeHTTP answers GET <BasePath><group>/<key> from peers
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest, ok := strings.CutPrefix(req.URL.EscapedPath(), p.opts.BasePath)
+	groupName, escapedKey, ok2 := strings.Cut(rest, "/")
+	if !ok || !ok2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	groupName, err1 := url.PathUnescape(groupName)
+	key, err2 := url.PathUnescape(escapedKey)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	g := p.groups[groupName]
+	p.mu.RUnlock()
+	if g == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+
+	value, err := g.getLocal(req.Context(), key)
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// RemoteError is an error reported by the peer that owns a key, as
+// opposed to a failure to reach it
+type RemoteError struct {
+	Peer   string
+	Status int
+	Msg    string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("distcache: peer %s: %d %s", e.Peer, e.Status, e.Msg)
+}
+
+// Unwrap makes a remote 404 match cache.ErrNotFound
+func (e *RemoteError) Unwrap() error {
+	if e.Status == http.StatusNotFound {
+		return cache.ErrNotFound
+	}
+	return nil
+}
+
+// httpPeer fetches from one other node
+type httpPeer struct {
+	baseURL string // peer URL with the base path
+	client  *http.Client
+}
+
+func (h *httpPeer) Fetch(ctx context.Context, group, key string) ([]byte, error) {
+	u := h.baseURL + url.PathEscape(group) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("distcache: reading response from %s: %w", h.baseURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &RemoteError{Peer: h.baseURL, Status: resp.StatusCode, Msg: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
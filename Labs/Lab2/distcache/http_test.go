@@ -0,0 +1,106 @@
+package distcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	cache "iru.com"
+)
+
+// startNodes runs n pools on local HTTP servers, all sharing one group
+// whose getter records which node loaded which key
+func startNodes(t *testing.T, n int, getter func(node int, key string) ([]byte, error)) []*Group {
+	t.Helper()
+	var urls []string
+	var pools []*HTTPPool
+	var groups []*Group
+	for i := 0; i < n; i++ {
+		var pool *HTTPPool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pool.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		pool = NewHTTPPool(srv.URL, HTTPPoolOptions{})
+
+		node := i
+		g, err := pool.NewGroup("test", 1<<20, func(ctx context.Context, key string) ([]byte, error) {
+			return getter(node, key)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, srv.URL)
+		pools = append(pools, pool)
+		groups = append(groups, g)
+	}
+	for _, pool := range pools {
+		pool.Set(urls...)
+	}
+	return groups
+}
+
+func TestGroupLoadsOnOwnerOnly(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[string][]int)
+	groups := startNodes(t, 3, func(node int, key string) ([]byte, error) {
+		mu.Lock()
+		loads[key] = append(loads[key], node)
+		mu.Unlock()
+		return []byte("val-" + key), nil
+	})
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprint("key", i)
+		for _, g := range groups {
+			v, err := g.Get(context.Background(), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(v) != "val-"+key {
+				t.Errorf("Get(%s) = %q", key, v)
+			}
+		}
+	}
+	for key, nodes := range loads {
+		if len(nodes) != 1 {
+			t.Errorf("%s loaded on nodes %v, want exactly one", key, nodes)
+		}
+	}
+	if len(loads) != 10 {
+		t.Errorf("%d keys loaded, want 10", len(loads))
+	}
+}
+
+func TestGroupRemoteNotFound(t *testing.T) {
+	groups := startNodes(t, 2, func(node int, key string) ([]byte, error) {
+		return nil, fmt.Errorf("no row for %s: %w", key, cache.ErrNotFound)
+	})
+	for i := 0; i < 5; i++ {
+		for _, g := range groups {
+			if _, err := g.Get(context.Background(), fmt.Sprint("key", i)); !errors.Is(err, cache.ErrNotFound) {
+				t.Errorf("got %v, want ErrNotFound", err)
+			}
+		}
+	}
+}
+
+func TestGroupFallsBackWhenOwnerIsDown(t *testing.T) {
+	pool := NewHTTPPool("http://self", HTTPPoolOptions{})
+	g, err := pool.NewGroup("test", 1<<20, func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Set("http://127.0.0.1:1") // nothing listens here
+
+	v, err := g.Get(context.Background(), "key")
+	if err != nil || string(v) != "local" {
+		t.Errorf("Get = %q, %v; want the local value", v, err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package distcache is a peer-to-peer distributed cache in the style of
+// groupcache, built on the cache package.
+//
+// Every key has one owner among the peers, chosen by a consistent-hash
+// Ring. The owner loads missing keys from the backend and keeps them in
+// its main cache. Any other peer asked for the key fetches it from the
+// owner and keeps a copy in a small hot cache, so popular keys do not
+// all land on one node.
+package distcache
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Hash maps bytes to a point on the ring
+type Hash func(data []byte) uint32
+
+// Ring is a consistent-hash ring. Each node is placed at several points
+// (virtual nodes), which spreads keys evenly and means adding or removing
+// a node only moves the keys next to its points.
+//
+// A Ring is not safe for concurrent use; HTTPPool guards its own.
+type Ring struct {
+	replicas int
+	hash     Hash
+	points   []uint32          // sorted
+	owners   map[uint32]string // point -> node
+}
+
+// NewRing returns an empty ring placing each node at replicas points.
+// A nil hash uses CRC-32.
+func NewRing(replicas int, hash Hash) *Ring {
+	if replicas < 1 {
+		replicas = 1
+	}
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	return &Ring{replicas: replicas, hash: hash, owners: make(map[uint32]string)}
+}
+
+// Set replaces the ring's membership with nodes
+func (r *Ring) Set(nodes ...string) {
+	r.points = r.points[:0]
+	clear(r.owners)
+	for _, node := range nodes {
+		for i := 0; i < r.replicas; i++ {
+			p := r.hash([]byte(strconv.Itoa(i) + node))
+			if _, taken := r.owners[p]; taken {
+				continue // a collision keeps the first node's point
+			}
+			r.points = append(r.points, p)
+			r.owners[p] = node
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Owner returns the node that owns key, or "" if the ring is empty
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := r.hash([]byte(key))
+	// The owner is the first point clockwise from the key's hash
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// Len returns the number of points on the ring
+func (r *Ring) Len() int {
+	return len(r.points)
+}
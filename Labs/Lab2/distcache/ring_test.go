@@ -0,0 +1,49 @@
+package distcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingOwner(t *testing.T) {
+	r := NewRing(50, nil)
+	if owner := r.Owner("key"); owner != "" {
+		t.Errorf("empty ring owner = %q, want none", owner)
+	}
+
+	r.Set("a", "b", "c")
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[r.Owner(fmt.Sprint("key", i))]++
+	}
+	for _, node := range []string{"a", "b", "c"} {
+		// With 50 virtual nodes each node should get roughly a third
+		if n := counts[node]; n < 600 || n > 1400 {
+			t.Errorf("node %s owns %d of 3000 keys", node, n)
+		}
+	}
+}
+
+func TestRingAddMovesFewKeys(t *testing.T) {
+	r := NewRing(50, nil)
+	r.Set("a", "b", "c")
+	before := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprint("key", i)
+		before[key] = r.Owner(key)
+	}
+
+	r.Set("a", "b", "c", "d")
+	moved := 0
+	for key, owner := range before {
+		if now := r.Owner(key); now != owner {
+			moved++
+			if now != "d" {
+				t.Fatalf("key %s moved from %s to %s, not to the new node", key, owner, now)
+			}
+		}
+	}
+	if moved == 0 || moved > 1200 {
+		t.Errorf("%d of 3000 keys moved, want about a quarter", moved)
+	}
+}
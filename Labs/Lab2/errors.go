@@ -0,0 +1,51 @@
+package cache
+
+import "errors"
+
+// Sentinel errors returned by the cache package. Errors with more detail
+// wrap one of these, so match them with errors.Is.
+var (
+	// ErrNotFound is returned by Get when the key is missing or expired
+	ErrNotFound = errors.New("cache: key not found")
+
+	// ErrCachedNegative is matched by errors returned by Get for a key
+	// whose miss or load error was cached. See NegativeError.
+	ErrCachedNegative = errors.New("cache: cached negative result")
+
+	// ErrTooHeavy is returned by Put when a single entry weighs more than
+	// the whole cache capacity
+	ErrTooHeavy = errors.New("cache: entry is heavier than the cache capacity")
+
+	// ErrFull is returned by Put when the entry would only fit by evicting
+	// pinned entries
+	ErrFull = errors.New("cache: cache is full of pinned entries")
+
+	// ErrInvalidWeight is returned by Put when the weigher returns a
+	// negative weight
+	ErrInvalidWeight = errors.New("cache: invalid entry weight")
+
+	// ErrInvalidOption is returned by New for a bad configuration
+	ErrInvalidOption = errors.New("cache: invalid option")
+
+	// ErrQueueFull is returned by a write-behind cache when too many keys
+	// are waiting to be flushed
+	ErrQueueFull = errors.New("cache: write-behind queue is full")
+
+	// ErrClosed is returned by writes to a cache that has been closed
+	ErrClosed = errors.New("cache: cache is closed")
+)
+
+// NegativeError is returned by Get for a key with a cached negative result.
+// It matches both ErrCachedNegative and the cached error, so a cached
+// "not found" still satisfies errors.Is(err, ErrNotFound).
+type NegativeError struct {
+	Err error // the cached error, ErrNotFound for a plain miss
+}
+
+func (e *NegativeError) Error() string {
+	return "cache: cached negative result: " + e.Err.Error()
+}
+
+func (e *NegativeError) Unwrap() []error {
+	return []error{ErrCachedNegative, e.Err}
+}
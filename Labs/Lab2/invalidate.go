@@ -0,0 +1,167 @@
+package cache
+
+import "fmt"
+
+// PutWithTags is Put for an entry derived from the given tags, such as the
+// category or the user it was computed from. InvalidateTag removes every
+// entry carrying a tag at once. Writing the key again replaces its tags;
+// a plain Put leaves it with none.
+func (c *Cache[K, V]) PutWithTags(key K, value V, tags ...string) error {
+	return c.put(key, value, c.ttl, tags)
+}
+
+// InvalidateTag removes every entry carrying tag, reporting each with
+// ReasonDeleted, and returns how many were removed. It takes time in
+// proportion to that number, not to the size of the cache.
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	var removed []removal[K, V]
+
+	c.mu.Lock()
+	for e := range c.tags[tag] {
+		c.removeEntry(e)
+		removed = append(removed, removal[K, V]{e.key, e.value, ReasonDeleted})
+	}
+	c.mu.Unlock()
+
+	c.notify(removed)
+	return len(removed)
+}
+
+// InvalidatePrefix removes every entry whose key starts with prefix,
+// reporting each with ReasonDeleted, and returns how many were removed.
+// It needs a string-keyed cache built with WithPrefixIndex, and returns an
+// error wrapping ErrInvalidOption otherwise. Like InvalidateTag, it takes
+// time in proportion to the number of entries removed.
+func (c *Cache[K, V]) InvalidatePrefix(prefix string) (int, error) {
+	if c.prefixes == nil {
+		return 0, fmt.Errorf("%w: the cache has no prefix index", ErrInvalidOption)
+	}
+
+	var removed []removal[K, V]
+
+	c.mu.Lock()
+	c.prefixes.walk(prefix, func(e *entry[K, V]) {
+		removed = append(removed, removal[K, V]{e.key, e.value, ReasonDeleted})
+	})
+	// Removing entries prunes the trie, so collect them first
+	for _, r := range removed {
+		c.removeEntry(c.cache[r.key])
+	}
+	c.mu.Unlock()
+
+	c.notify(removed)
+	return len(removed), nil
+}
+
+// setTags replaces the tags of e in the tag index
+func (c *Cache[K, V]) setTags(e *entry[K, V], tags []string) {
+	c.untag(e)
+	if len(tags) == 0 {
+		return
+	}
+	if c.tags == nil {
+		c.tags = make(map[string]map[*entry[K, V]]struct{})
+	}
+	e.tags = append([]string(nil), tags...)
+	for _, tag := range e.tags {
+		set, ok := c.tags[tag]
+		if !ok {
+			set = make(map[*entry[K, V]]struct{})
+			c.tags[tag] = set
+		}
+		set[e] = struct{}{}
+	}
+}
+
+// untag removes e from the tag index
+func (c *Cache[K, V]) untag(e *entry[K, V]) {
+	for _, tag := range e.tags {
+		set := c.tags[tag]
+		delete(set, e)
+		if len(set) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	e.tags = nil
+}
+
+// trie indexes the entries of a string-keyed cache by key, one node per
+// byte, so that all keys under a prefix can be found without a full scan
+type trie[K comparable, V any] struct {
+	root trieNode[K, V]
+}
+
+type trieNode[K comparable, V any] struct {
+	children map[byte]*trieNode[K, V]
+	e        *entry[K, V] // entry whose key ends here, if any
+}
+
+func (t *trie[K, V]) insert(key string, e *entry[K, V]) {
+	n := &t.root
+	for i := 0; i < len(key); i++ {
+		child, ok := n.children[key[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[byte]*trieNode[K, V])
+			}
+			child = &trieNode[K, V]{}
+			n.children[key[i]] = child
+		}
+		n = child
+	}
+	n.e = e
+}
+
+// remove deletes key and prunes the nodes left without entries below them
+func (t *trie[K, V]) remove(key string) {
+	path := make([]*trieNode[K, V], 0, len(key)+1)
+	n := &t.root
+	for i := 0; i < len(key); i++ {
+		path = append(path, n)
+		if n = n.children[key[i]]; n == nil {
+			return
+		}
+	}
+	n.e = nil
+	for i := len(key) - 1; i >= 0 && n.e == nil && len(n.children) == 0; i-- {
+		n = path[i]
+		delete(n.children, key[i])
+	}
+}
+
+// walk calls fn for every entry whose key starts with prefix
+func (t *trie[K, V]) walk(prefix string, fn func(*entry[K, V])) {
+	n := &t.root
+	for i := 0; i < len(prefix) && n != nil; i++ {
+		n = n.children[prefix[i]]
+	}
+	if n != nil {
+		n.each(fn)
+	}
+}
+
+func (n *trieNode[K, V]) each(fn func(*entry[K, V])) {
+	if n.e != nil {
+		fn(n.e)
+	}
+	for _, child := range n.children {
+		child.each(fn)
+	}
+}
+
+// indexKey adds e to the prefix index, if the cache has one
+func (c *Cache[K, V]) indexKey(e *entry[K, V]) {
+	if c.prefixes != nil {
+		c.prefixes.insert(any(e.key).(string), e)
+	}
+}
+
+// unindex removes e from the prefix and tag indexes
+func (c *Cache[K, V]) unindex(e *entry[K, V]) {
+	if c.prefixes != nil {
+		c.prefixes.remove(any(e.key).(string))
+	}
+	if len(e.tags) > 0 {
+		c.untag(e)
+	}
+}
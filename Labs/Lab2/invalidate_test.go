@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	var removed []string
+	c, err := New[string, string](WithCapacity(8),
+		WithOnRemove(func(k, v string, r RemovalReason) {
+			if r == ReasonDeleted {
+				removed = append(removed, k)
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.PutWithTags("shoe1", "val", "shoes", "sale")
+	c.PutWithTags("shoe2", "val", "shoes")
+	c.PutWithTags("hat1", "val", "hats", "sale")
+	c.Put("other", "val")
+
+	if n := c.InvalidateTag("sale"); n != 2 {
+		t.Errorf("InvalidateTag(sale) = %d, want 2", n)
+	}
+	slices.Sort(removed)
+	if !slices.Equal(removed, []string{"hat1", "shoe1"}) {
+		t.Errorf("removed %v", removed)
+	}
+	if n := c.InvalidateTag("shoes"); n != 1 {
+		t.Errorf("InvalidateTag(shoes) = %d, want 1", n)
+	}
+	if n := c.InvalidateTag("hats"); n != 0 {
+		t.Errorf("InvalidateTag(hats) = %d, want 0", n)
+	}
+	if c.Len() != 1 || len(c.tags) != 0 {
+		t.Errorf("Len = %d, %d tags left; want 1 entry and no tags", c.Len(), len(c.tags))
+	}
+}
+
+func TestPutReplacesTags(t *testing.T) {
+	c, _ := New[string, string](WithCapacity(8))
+	c.PutWithTags("key1", "val1", "old")
+	c.PutWithTags("key1", "val2", "new")
+	if n := c.InvalidateTag("old"); n != 0 {
+		t.Errorf("old tag still removed %d entries", n)
+	}
+	c.Put("key1", "val3")
+	if n := c.InvalidateTag("new"); n != 0 {
+		t.Errorf("plain Put kept the tags; removed %d entries", n)
+	}
+	if !c.Contains("key1") {
+		t.Error("key1 is gone")
+	}
+}
+
+func TestTagsFollowEviction(t *testing.T) {
+	c, _ := New[string, string](WithCapacity(1))
+	c.PutWithTags("key1", "val1", "tag")
+	c.Put("key2", "val2") // evicts key1
+	if len(c.tags) != 0 {
+		t.Errorf("evicted entry left in the tag index: %v", c.tags)
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	c, err := New[string, string](WithCapacity(8), WithPrefixIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"user:1", "user:10", "user:2", "users", "order:1", ""} {
+		c.Put(k, "val")
+	}
+
+	n, err := c.InvalidatePrefix("user:1")
+	if err != nil || n != 2 {
+		t.Errorf("InvalidatePrefix(user:1) = %d, %v; want 2", n, err)
+	}
+	if n, _ := c.InvalidatePrefix("user"); n != 2 {
+		t.Errorf("InvalidatePrefix(user) = %d, want 2", n)
+	}
+	if n, _ := c.InvalidatePrefix("nope"); n != 0 {
+		t.Errorf("InvalidatePrefix(nope) = %d, want 0", n)
+	}
+	if !c.Contains("order:1") || !c.Contains("") {
+		t.Error("unrelated keys were removed")
+	}
+
+	// The empty prefix matches everything, and leaves an empty trie
+	if n, _ := c.InvalidatePrefix(""); n != 2 {
+		t.Errorf("InvalidatePrefix(\"\") = %d, want 2", n)
+	}
+	if len(c.prefixes.root.children) != 0 || c.prefixes.root.e != nil {
+		t.Error("trie was not pruned")
+	}
+}
+
+func TestInvalidatePrefixNeedsIndex(t *testing.T) {
+	c, _ := New[string, string](WithCapacity(1))
+	if _, err := c.InvalidatePrefix("a"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("got %v, want ErrInvalidOption", err)
+	}
+	if _, err := New[int, string](WithCapacity(1), WithPrefixIndex()); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("prefix index on int keys: got %v, want ErrInvalidOption", err)
+	}
+}
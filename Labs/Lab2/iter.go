@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"container/list"
+	"iter"
+)
+
+// All returns an iterator over the cache's entries, most recently used
+// first. Iterating does not change recency, count hits or remove expired
+// entries, and the cache is not locked while the loop body runs, so the
+// body may call any cache method.
+//
+// Changes made during iteration are handled safely, with these rules:
+// every entry is yielded at most once; entries added, written or read
+// after the iteration started are not yielded, since they have moved
+// ahead of it; entries removed or expired before the iteration reaches
+// them are not yielded. Entries left untouched throughout are all yielded.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.mu.Lock()
+		// Each step resumes at the first entry whose recency stamp is below
+		// that of the entry yielded last, which is normally the one right
+		// after it in the queue.
+		bound := c.seq + 1
+		next := entryOf[K, V](c.queue.Front())
+		var nextSeq uint64
+		if next != nil {
+			nextSeq = next.seq
+		}
+
+		for {
+			if next != nil && (next.elem == nil || next.seq != nextSeq) {
+				// The entry we meant to visit was removed or moved to the
+				// front since the last step; look again from the top
+				next = c.firstBelow(bound)
+			}
+			now := c.clock.Now()
+			for next != nil && next.expiredAt(now) {
+				next = entryOf[K, V](next.elem.Next())
+			}
+			if next == nil {
+				c.mu.Unlock()
+				return
+			}
+
+			key, value := next.key, next.value
+			bound = next.seq
+			next = entryOf[K, V](next.elem.Next())
+			if next != nil {
+				nextSeq = next.seq
+			}
+			c.mu.Unlock()
+
+			if !yield(key, value) {
+				return
+			}
+			c.mu.Lock()
+		}
+	}
+}
+
+// Keys returns an iterator over the cache's keys, most recently used
+// first. It follows the same rules as All.
+func (c *Cache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range c.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the cache's values, most recently used
+// first. It follows the same rules as All.
+func (c *Cache[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// firstBelow returns the most recently used entry whose recency stamp is
+// below bound. The caller must hold c.mu.
+func (c *Cache[K, V]) firstBelow(bound uint64) *entry[K, V] {
+	for elem := c.queue.Front(); elem != nil; elem = elem.Next() {
+		if e := elem.Value.(*entry[K, V]); e.seq < bound {
+			return e
+		}
+	}
+	return nil
+}
+
+// entryOf returns the entry stored in a queue element, or nil
+func entryOf[K comparable, V any](elem *list.Element) *entry[K, V] {
+	if elem == nil {
+		return nil
+	}
+	return elem.Value.(*entry[K, V])
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func newFilled(t *testing.T, keys ...string) *Cache[string, int] {
+	t.Helper()
+	c, err := New[string, int](WithCapacity(int64(len(keys) + 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range keys {
+		c.Put(k, i)
+	}
+	return c
+}
+
+func TestIterationOrder(t *testing.T) {
+	c := newFilled(t, "a", "b", "c", "d")
+	c.Get("b")
+
+	if got, want := slices.Collect(c.Keys()), []string{"b", "d", "c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	if got, want := slices.Collect(c.Values()), []int{1, 3, 2, 0}; !slices.Equal(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+
+	// Iterating again gives the same order, so it did not touch recency
+	if got := slices.Collect(c.Keys()); got[0] != "b" || got[3] != "a" {
+		t.Errorf("iteration changed recency: %v", got)
+	}
+	if s := c.Stats(); s.Hits != 1 {
+		t.Errorf("iteration counted hits: %d", s.Hits)
+	}
+}
+
+func TestIterationWhileModifying(t *testing.T) {
+	c := newFilled(t, "a", "b", "c", "d", "e") // order e d c b a
+
+	var got []string
+	for k := range c.Keys() {
+		got = append(got, k)
+		switch k {
+		case "e":
+			c.Delete("c")   // removed before it is reached
+			c.Put("new", 9) // added after the iteration started
+		case "d":
+			c.Get("d") // moves the entry just yielded
+			c.Get("a") // moves an entry not yet reached
+		}
+	}
+	if want := []string{"e", "d", "b"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIterationReadEveryKey(t *testing.T) {
+	c := newFilled(t, "a", "b", "c", "d")
+	var got []string
+	for k := range c.Keys() {
+		got = append(got, k)
+		c.Get(k)
+	}
+	if want := []string{"d", "c", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIterationBreak(t *testing.T) {
+	c := newFilled(t, "a", "b", "c")
+	for range c.All() {
+		break
+	}
+	// The lock was released when the loop stopped
+	c.Put("d", 3)
+}
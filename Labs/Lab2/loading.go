@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+)
+
+// Loader fetches the value for a key that is not in the cache
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// LoadingCache is a read-through cache: Get fills misses by calling the
+// loader and storing the result.
+//
+// Concurrent Gets for the same missing key share a single loader call, and
+// all of them receive its value or its error. Errors are not cached, so the
+// next Get after a failure calls the loader again, unless the cache was
+// built with WithNegativeCache: then the error is remembered for the
+// negative TTL and returned as a *NegativeError without calling the loader.
+//
+// With WithRefreshAfter or WithStaleWhileRevalidate, hot keys are reloaded
+// in the background before or just after they expire, so callers keep
+// getting the current value instead of waiting for the loader. Both need
+// the wrapped cache to be a *Cache, and are ignored otherwise.
+type LoadingCache[K comparable, V any] struct {
+	cache  Cacher[K, V]
+	loader Loader[K, V]
+	opts   loadingOptions
+
+	mu         sync.Mutex
+	calls      map[K]*call[V] // loads in flight
+	refreshing chan struct{}  // one token per background refresh running
+
+	stats statsCounter // load counters only; the rest come from cache
+}
+
+// call is a loader call in flight and the callers waiting on it
+type call[V any] struct {
+	done    chan struct{} // closed when val and err are set
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+	refresh bool // a background reload of a value that is still cached
+
+	// forgotten is set when a Put or Delete of the key races with the load,
+	// so the loaded value, which may already be out of date, is not stored
+	forgotten bool
+}
+
+// entryLookup is implemented by *Cache; LoadingCache uses it to see how
+// old an entry is
+type entryLookup[K comparable, V any] interface {
+	lookup(key K, grace time.Duration) (value V, age time.Duration, stale bool, err error)
+}
+
+// negativePutter is implemented by *Cache; LoadingCache uses it to cache
+// loader errors
+type negativePutter[K comparable] interface {
+	PutNegative(key K, err error) error
+}
+
+// LoadingOption configures a LoadingCache
+type LoadingOption func(*loadingOptions)
+
+type loadingOptions struct {
+	refreshAfter  time.Duration
+	staleFor      time.Duration
+	maxRefreshing int
+}
+
+// WithRefreshAfter reloads an entry in the background once it is older
+// than d. The Get that notices returns the current value right away.
+// Choose d shorter than the cache TTL so hot keys never expire.
+func WithRefreshAfter(d time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		o.refreshAfter = d
+	}
+}
+
+// WithStaleWhileRevalidate lets Get return an entry that expired less than
+// grace ago, reloading it in the background. Older entries are a miss.
+func WithStaleWhileRevalidate(grace time.Duration) LoadingOption {
+	return func(o *loadingOptions) {
+		o.staleFor = grace
+	}
+}
+
+// WithMaxConcurrentRefreshes caps how many background reloads run at once,
+// 16 by default. When the cap is reached the entry is served as it is and
+// a later Get tries again.
+func WithMaxConcurrentRefreshes(n int) LoadingOption {
+	return func(o *loadingOptions) {
+		o.maxRefreshing = n
+	}
+}
+
+// NewLoadingCache wraps c so that misses are filled by loader
+func NewLoadingCache[K comparable, V any](c Cacher[K, V], loader Loader[K, V], opts ...LoadingOption) *LoadingCache[K, V] {
+	o := loadingOptions{maxRefreshing: 16}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &LoadingCache[K, V]{
+		cache:      c,
+		loader:     loader,
+		opts:       o,
+		calls:      make(map[K]*call[V]),
+		refreshing: make(chan struct{}, max(o.maxRefreshing, 1)),
+	}
+}
+
+// Get returns the cached value for key, loading it on a miss.
+//
+// If ctx is done before the value is ready, Get returns ctx.Err(). The load
+// keeps running for any other callers waiting on it, and is cancelled only
+// once every waiter has given up.
+func (lc *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	v, err := lc.cached(key)
+	if err == nil || errors.Is(err, ErrCachedNegative) {
+		return v, err
+	}
+
+	lc.mu.Lock()
+	cl, ok := lc.calls[key]
+	if !ok {
+		// The load gets its own context so that the first caller giving up
+		// does not fail everyone else. It keeps ctx's values for tracing.
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		cl = &call[V]{done: make(chan struct{}), cancel: cancel}
+		lc.calls[key] = cl
+		go lc.load(loadCtx, key, cl)
+	}
+	cl.waiters++
+	lc.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.val, cl.err
+	case <-ctx.Done():
+		lc.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 {
+			cl.cancel()
+			if lc.calls[key] == cl {
+				delete(lc.calls, key)
+			}
+		}
+		lc.mu.Unlock()
+		var zeroVal V
+		return zeroVal, ctx.Err()
+	}
+}
+
+// cached returns the cached value for key, starting a background refresh
+// if the entry is due for one
+func (lc *LoadingCache[K, V]) cached(key K) (V, error) {
+	lk, ok := lc.cache.(entryLookup[K, V])
+	if !ok || (lc.opts.refreshAfter <= 0 && lc.opts.staleFor <= 0) {
+		return lc.cache.Get(key)
+	}
+
+	v, age, stale, err := lk.lookup(key, lc.opts.staleFor)
+	if err == nil && (stale || (lc.opts.refreshAfter > 0 && age >= lc.opts.refreshAfter)) {
+		lc.refresh(key)
+	}
+	return v, err
+}
+
+// refresh reloads key in the background unless it is already loading or
+// too many refreshes are running. The old value stays cached until the
+// new one arrives, and is kept if the reload fails.
+func (lc *LoadingCache[K, V]) refresh(key K) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if _, loading := lc.calls[key]; loading {
+		return
+	}
+	select {
+	case lc.refreshing <- struct{}{}:
+	default:
+		return
+	}
+
+	// The refresh counts as a waiter of its own, so Gets that join it and
+	// give up cannot cancel it
+	ctx, cancel := context.WithCancel(context.Background())
+	cl := &call[V]{done: make(chan struct{}), cancel: cancel, waiters: 1, refresh: true}
+	lc.calls[key] = cl
+	go func() {
+		defer func() { <-lc.refreshing }()
+		lc.load(ctx, key, cl)
+	}()
+}
+
+// load runs the loader for key and publishes the result to cl's waiters
+func (lc *LoadingCache[K, V]) load(ctx context.Context, key K, cl *call[V]) {
+	defer cl.cancel()
+
+	start := time.Now()
+	cl.val, cl.err = lc.callLoader(ctx, key)
+	lc.stats.recordLoad(time.Since(start), cl.err)
+
+	lc.mu.Lock()
+	store := !cl.forgotten
+	if lc.calls[key] == cl {
+		delete(lc.calls, key)
+	}
+	lc.mu.Unlock()
+
+	switch {
+	case !store:
+	case cl.err == nil:
+		// A value too heavy to cache is still returned to the waiters
+		_ = lc.cache.Put(key, cl.val)
+	case cacheableError(cl.err) && !cl.refresh:
+		// A failed refresh keeps the old value rather than caching the error
+		if np, ok := lc.cache.(negativePutter[K]); ok {
+			_ = np.PutNegative(key, cl.err)
+		}
+	}
+	close(cl.done)
+}
+
+// cacheableError reports whether a loader error says something about the
+// key rather than about the call: running out of time is not worth caching
+func cacheableError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// callLoader runs the loader, turning a panic into an error so that the
+// waiters are never left blocked
+func (lc *LoadingCache[K, V]) callLoader(ctx context.Context, key K) (v V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cache: loader panicked for key %v: %v", key, r)
+		}
+	}()
+	return lc.loader(ctx, key)
+}
+
+// Put stores a value directly, bypassing the loader
+func (lc *LoadingCache[K, V]) Put(key K, value V) error {
+	lc.forget(key)
+	return lc.cache.Put(key, value)
+}
+
+// Delete removes a key from the cache and reports whether it was present
+func (lc *LoadingCache[K, V]) Delete(key K) bool {
+	lc.forget(key)
+	return lc.cache.Delete(key)
+}
+
+// forget detaches any load in flight for key, so its result is not stored
+// over a newer write. Callers already waiting on it still get its result.
+func (lc *LoadingCache[K, V]) forget(key K) {
+	lc.mu.Lock()
+	if cl, ok := lc.calls[key]; ok {
+		cl.forgotten = true
+		delete(lc.calls, key)
+	}
+	lc.mu.Unlock()
+}
+
+// All iterates over the cached entries without loading anything.
+// See Cache.All for how it behaves when the cache changes.
+func (lc *LoadingCache[K, V]) All() iter.Seq2[K, V] {
+	return lc.cache.All()
+}
+
+// Keys iterates over the cached keys
+func (lc *LoadingCache[K, V]) Keys() iter.Seq[K] {
+	return lc.cache.Keys()
+}
+
+// Values iterates over the cached values
+func (lc *LoadingCache[K, V]) Values() iter.Seq[V] {
+	return lc.cache.Values()
+}
+
+// Stats returns the underlying cache's statistics together with the
+// loader counters
+func (lc *LoadingCache[K, V]) Stats() Stats {
+	s := lc.cache.Stats()
+	l := lc.stats.snapshot()
+	s.LoadSuccesses += l.LoadSuccesses
+	s.LoadErrors += l.LoadErrors
+	s.LoadTime += l.LoadTime
+	return s
+}
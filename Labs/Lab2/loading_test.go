@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCacheDeduplicates(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	lc := NewLoadingCache(NewCacher[string, string](8), func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "val-" + key, nil
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := lc.Get(context.Background(), "key")
+			if err == nil && v != "val-key" {
+				err = errors.New("wrong value " + v)
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // let the callers pile up on the load
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	if s := lc.Stats(); s.LoadSuccesses != 1 {
+		t.Errorf("LoadSuccesses = %d, want 1", s.LoadSuccesses)
+	}
+
+	// Now cached, the loader is not called again
+	lc.Get(context.Background(), "key")
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times after a hit, want 1", n)
+	}
+}
+
+func TestLoadingCacheErrors(t *testing.T) {
+	errBackend := errors.New("backend down")
+	lc := NewLoadingCache(NewCacher[string, string](8), func(ctx context.Context, key string) (string, error) {
+		return "", errBackend
+	})
+	if _, err := lc.Get(context.Background(), "key"); !errors.Is(err, errBackend) {
+		t.Errorf("got %v, want the loader's error", err)
+	}
+	if s := lc.Stats(); s.LoadErrors != 1 || s.Entries != 0 {
+		t.Errorf("LoadErrors/Entries = %d/%d, want 1/0", s.LoadErrors, s.Entries)
+	}
+}
+
+func TestLoadingCacheCancel(t *testing.T) {
+	loadCancelled := make(chan struct{})
+	lc := NewLoadingCache(NewCacher[string, string](8), func(ctx context.Context, key string) (string, error) {
+		<-ctx.Done()
+		close(loadCancelled)
+		return "", ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := lc.Get(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// The only waiter gave up, so the load itself is cancelled
+	select {
+	case <-loadCancelled:
+	case <-time.After(time.Second):
+		t.Error("loader context was not cancelled")
+	}
+}
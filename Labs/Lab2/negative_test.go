@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNegativeCache(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, err := New[string, string](WithCapacity(4), WithNegativeCache(2, time.Second), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("key1"); errors.Is(err, ErrCachedNegative) {
+		t.Errorf("plain miss: got %v, want a real miss", err)
+	}
+	if err := c.PutNegative("key1", nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Get("key1")
+	var neg *NegativeError
+	if !errors.As(err, &neg) || !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrCachedNegative) {
+		t.Errorf("cached miss: got %v, want a NegativeError matching ErrNotFound", err)
+	}
+
+	s := c.Stats()
+	if s.Misses != 1 || s.NegativeHits != 1 || s.NegativeEntries != 1 || s.Entries != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := c.Get("key1"); errors.Is(err, ErrCachedNegative) {
+		t.Errorf("after negative TTL: got %v, want a real miss", err)
+	}
+}
+
+func TestNegativeCacheCapacityIsSeparate(t *testing.T) {
+	c, err := New[string, string](WithCapacity(1), WithNegativeCache(2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("key1", "val1")
+	c.PutNegative("key2", nil)
+	c.PutNegative("key3", nil)
+	c.PutNegative("key4", nil) // evicts the negative for key2 only
+
+	if v, err := c.Get("key1"); err != nil || v != "val1" {
+		t.Errorf("Get(key1) = %q, %v; negatives must not evict values", v, err)
+	}
+	if _, err := c.Get("key2"); errors.Is(err, ErrCachedNegative) {
+		t.Error("key2 is still cached as negative")
+	}
+	if _, err := c.Get("key4"); !errors.Is(err, ErrCachedNegative) {
+		t.Errorf("Get(key4) = %v, want a cached negative", err)
+	}
+}
+
+func TestNegativeCacheWrites(t *testing.T) {
+	var reasons []RemovalReason
+	c, err := New[string, string](WithCapacity(4), WithNegativeCache(4, time.Minute),
+		WithOnRemove(func(k, v string, r RemovalReason) { reasons = append(reasons, r) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Put("key1", "val1")
+	c.PutNegative("key1", nil)
+	if _, err := c.Get("key1"); !errors.Is(err, ErrCachedNegative) {
+		t.Errorf("PutNegative over a value: got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonReplaced {
+		t.Errorf("removals = %v, want [replaced]", reasons)
+	}
+
+	c.Put("key1", "val2")
+	if v, err := c.Get("key1"); err != nil || v != "val2" {
+		t.Errorf("Put over a negative: got %q, %v", v, err)
+	}
+
+	c.PutNegative("key2", nil)
+	if !c.Delete("key2") {
+		t.Error("Delete of a negative entry returned false")
+	}
+	c.PutNegative("key3", nil)
+	c.Clear()
+	if _, err := c.Get("key3"); errors.Is(err, ErrCachedNegative) {
+		t.Error("Clear kept a negative entry")
+	}
+}
+
+func TestNegativeCacheValidates(t *testing.T) {
+	if _, err := New[string, string](WithCapacity(1), WithNegativeCache(1, 0)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("zero negative TTL: got %v, want ErrInvalidOption", err)
+	}
+	c, _ := New[string, string](WithCapacity(1))
+	if err := c.PutNegative("key1", nil); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("PutNegative without WithNegativeCache: got %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestLoadingCacheCachesErrors(t *testing.T) {
+	errGone := errors.New("gone")
+	var calls atomic.Int32
+	c, _ := New[string, string](WithCapacity(4), WithNegativeCache(4, time.Minute))
+	lc := NewLoadingCache[string, string](c, func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		if key == "slow" {
+			return "", context.DeadlineExceeded
+		}
+		return "", errGone
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := lc.Get(context.Background(), "key1"); !errors.Is(err, errGone) {
+			t.Fatalf("Get #%d = %v, want errGone", i, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	// Timeouts say nothing about the key, so they are not cached
+	lc.Get(context.Background(), "slow")
+	lc.Get(context.Background(), "slow")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("loader called %d times, want 3", n)
+	}
+}
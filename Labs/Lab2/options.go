@@ -0,0 +1,96 @@
+package cache
+
+import "time"
+
+// Option configures a cache built by New or NewCacher
+type Option func(*options)
+
+// options holds settings before the key and value types are known.
+// Typed settings are stored as any and checked by New.
+type options struct {
+	capacity int64
+	ttl      time.Duration
+	policy   Policy
+	clock    Clock
+	weigher  any // Weigher[K, V]
+	onRemove any // RemovalFunc[K, V]
+
+	negative    bool
+	negCapacity int64
+	negTTL      time.Duration
+
+	prefixIndex bool
+}
+
+// WithCapacity sets the cache's budget: a number of entries, or a total
+// weight when WithWeigher is also given. It must be positive.
+func WithCapacity(capacity int64) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithTTL makes entries expire ttl after they were last written.
+// The default, 0, keeps entries until they are evicted.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithPolicy sets the eviction policy. The default is LRU.
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithClock sets the time source used for TTLs, mainly so tests can
+// control time. The default is the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Weigher returns the cost of keeping an entry, in whatever unit the cache
+// capacity is given in (bytes, for example). It must not be negative and
+// must return the same result for the same key and value.
+type Weigher[K comparable, V any] func(key K, value V) int64
+
+// WithWeigher bounds the cache by total weight instead of entry count.
+// The capacity becomes the weight budget.
+func WithWeigher[K comparable, V any](fn Weigher[K, V]) Option {
+	return func(o *options) {
+		o.weigher = fn
+	}
+}
+
+// WithOnRemove registers a callback for every entry that leaves the cache,
+// whether it was evicted, expired, deleted or replaced. See RemovalFunc for
+// when it runs.
+func WithOnRemove[K comparable, V any](fn RemovalFunc[K, V]) Option {
+	return func(o *options) {
+		o.onRemove = fn
+	}
+}
+
+// WithNegativeCache lets the cache remember misses: keys stored with
+// PutNegative, and loader errors when the cache backs a LoadingCache.
+// Negative entries are kept apart from values, up to capacity of them for
+// ttl each, so they never push out real entries. Both must be positive.
+func WithNegativeCache(capacity int64, ttl time.Duration) Option {
+	return func(o *options) {
+		o.negative = true
+		o.negCapacity = capacity
+		o.negTTL = ttl
+	}
+}
+
+// WithPrefixIndex keeps the keys of a string-keyed cache in a trie so that
+// InvalidatePrefix can find them quickly. It costs some memory per key.
+func WithPrefixIndex() Option {
+	return func(o *options) {
+		o.prefixIndex = true
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestNewValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"no capacity", nil},
+		{"zero capacity", []Option{WithCapacity(0)}},
+		{"negative TTL", []Option{WithCapacity(1), WithTTL(-time.Second)}},
+		{"unknown policy", []Option{WithCapacity(1), WithPolicy(Policy(42))}},
+		{"nil clock", []Option{WithCapacity(1), WithClock(nil)}},
+		{"mismatched callback", []Option{WithCapacity(1), WithOnRemove(func(k int, v int, r RemovalReason) {})}},
+	}
+	for _, tt := range tests {
+		if _, err := New[string, string](tt.opts...); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: got %v, want ErrInvalidOption", tt.name, err)
+		}
+	}
+}
+
+func TestNewCacherPanicsOnZeroSize(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewCacher(0) did not panic")
+		}
+	}()
+	NewCacher[string, string](0)
+}
+
+func TestGetNotFound(t *testing.T) {
+	c, err := New[string, string](WithCapacity(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var reasons []RemovalReason
+	c, err := New[string, string](WithCapacity(4), WithTTL(time.Minute), WithClock(clock),
+		WithOnRemove(func(k, v string, r RemovalReason) { reasons = append(reasons, r) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Put("key1", "val1")
+	c.PutWithTTL("key2", "val2", 0) // never expires
+	c.PutWithTTL("key3", "val3", 2*time.Minute)
+
+	clock.Advance(time.Minute)
+	if _, err := c.Get("key1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("key1 after its TTL: got %v, want ErrNotFound", err)
+	}
+	if _, err := c.Get("key3"); err != nil {
+		t.Errorf("key3 before its TTL: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if n := c.PurgeExpired(); n != 1 {
+		t.Errorf("PurgeExpired() = %d, want 1", n)
+	}
+	if _, err := c.Get("key2"); err != nil {
+		t.Errorf("key2 without a TTL: %v", err)
+	}
+	if len(reasons) != 2 || reasons[0] != ReasonExpired || reasons[1] != ReasonExpired {
+		t.Errorf("removal reasons = %v, want two expirations", reasons)
+	}
+}
+
+func TestContainsTTLAndClear(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := New[string, string](WithCapacity(4), WithClock(clock))
+	c.PutWithTTL("key1", "val1", time.Minute)
+	c.Put("key2", "val2")
+
+	if ttl, ok := c.TTL("key1"); !ok || ttl != time.Minute {
+		t.Errorf("TTL(key1) = %v, %v; want 1m", ttl, ok)
+	}
+	if ttl, ok := c.TTL("key2"); !ok || ttl != 0 {
+		t.Errorf("TTL(key2) = %v, %v; want 0 for no expiry", ttl, ok)
+	}
+	if !c.Contains("key1") || c.Contains("missing") {
+		t.Error("Contains gave the wrong answer")
+	}
+	if s := c.Stats(); s.Hits+s.Misses != 0 {
+		t.Errorf("Contains and TTL counted %d requests", s.Hits+s.Misses)
+	}
+
+	c.Clear()
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() after Clear = %d", n)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+// Pin exempts key from eviction until Unpin is called. A pinned entry still
+// counts towards the capacity, and still expires and can be deleted or
+// replaced as usual; replacing it keeps it pinned. Once pinned entries fill
+// the cache, Puts that would need their room fail with ErrFull.
+//
+// Pin returns ErrNotFound if the key is missing or expired. Pinning a
+// pinned key does nothing.
+func (c *Cache[K, V]) Pin(key K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.cache[key]
+	if !ok || e.expiredAt(c.clock.Now()) {
+		return ErrNotFound
+	}
+	if !e.pinned {
+		c.policy.remove(e)
+		e.pinned = true
+		c.pinnedCount++
+		c.pinnedWeight += e.weight
+	}
+	return nil
+}
+
+// Unpin makes key evictable again and reports whether it was pinned. The
+// policy sees it as newly added, so under LFU its use count starts over.
+func (c *Cache[K, V]) Unpin(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.cache[key]
+	if !ok || !e.pinned {
+		return false
+	}
+	e.pinned = false
+	c.pinnedCount--
+	c.pinnedWeight -= e.weight
+	c.policy.add(e)
+	return true
+}
+
+// Pinned reports whether key is in the cache and pinned
+func (c *Cache[K, V]) Pinned(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	return ok && e.pinned
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPinSurvivesEviction(t *testing.T) {
+	for _, p := range Policies {
+		c, _ := New[string, string](WithCapacity(2), WithPolicy(p))
+		c.Put("config", "val")
+		if err := c.Pin("config"); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"key1", "key2", "key3"} {
+			if err := c.Put(k, "val"); err != nil {
+				t.Fatalf("%v: Put(%s) = %v", p, k, err)
+			}
+		}
+		if !c.Contains("config") || !c.Contains("key3") || c.Len() != 2 {
+			t.Errorf("%v: pinned entry was evicted", p)
+		}
+	}
+}
+
+func TestPutIntoPinnedCache(t *testing.T) {
+	c, _ := New[string, string](WithCapacity(2))
+	c.Put("key1", "val1")
+	c.Put("key2", "val2")
+	c.Pin("key1")
+	c.Pin("key2")
+
+	if err := c.Put("key3", "val3"); !errors.Is(err, ErrFull) {
+		t.Errorf("Put into a fully pinned cache = %v, want ErrFull", err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	// Replacing a pinned entry needs no room and keeps the pin
+	if err := c.Put("key1", "new"); err != nil {
+		t.Errorf("replacing a pinned entry: %v", err)
+	}
+	if !c.Pinned("key1") {
+		t.Error("replaced entry lost its pin")
+	}
+
+	if !c.Unpin("key1") || c.Unpin("key1") {
+		t.Error("Unpin should report true once")
+	}
+	if err := c.Put("key3", "val3"); err != nil {
+		t.Errorf("Put after Unpin = %v", err)
+	}
+	if c.Contains("key1") || !c.Contains("key2") {
+		t.Error("expected the unpinned key1 to be evicted")
+	}
+}
+
+func TestPinWeight(t *testing.T) {
+	c, _ := New[string, []byte](WithCapacity(10), WithWeigher(byteWeigher))
+	c.Put("a", []byte("aaaaa"))
+	c.Pin("a")
+	if err := c.Put("b", []byte("bbbbbb")); !errors.Is(err, ErrFull) {
+		t.Errorf("Put(b) needing 6 with 5 pinned = %v, want ErrFull", err)
+	}
+	if err := c.Put("b", []byte("bbbbb")); err != nil {
+		t.Errorf("Put(b) = %v", err)
+	}
+
+	s := c.Stats()
+	if s.PinnedEntries != 1 || s.PinnedWeight != 5 || s.Weight != 10 {
+		t.Errorf("stats = %+v", s)
+	}
+	c.Delete("a")
+	if s := c.Stats(); s.PinnedEntries != 0 || s.PinnedWeight != 0 {
+		t.Errorf("after Delete: %d pinned, weight %d", s.PinnedEntries, s.PinnedWeight)
+	}
+}
+
+func TestPinMissing(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := New[string, string](WithCapacity(2), WithTTL(time.Second), WithClock(clock))
+	if err := c.Pin("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Pin(missing) = %v, want ErrNotFound", err)
+	}
+	c.Put("key1", "val1")
+	clock.Advance(time.Second)
+	if err := c.Pin("key1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Pin(expired) = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+// Policy chooses which entry to evict when the cache is full
+type Policy int
+
+const (
+	LRU  Policy = iota // evict the least recently used entry
+	FIFO               // evict the oldest entry, however often it is read
+	LFU                // evict the least frequently used entry, breaking ties by recency
+)
+
+// Policies lists every eviction policy, in declaration order
+var Policies = []Policy{LRU, FIFO, LFU}
+
+func (p Policy) String() string {
+	switch p {
+	case LRU:
+		return "LRU"
+	case FIFO:
+		return "FIFO"
+	case LFU:
+		return "LFU"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// evictor is the bookkeeping behind a Policy. The cache calls it under its
+// lock, so implementations need no locking of their own.
+type evictor[K comparable, V any] interface {
+	add(e *entry[K, V])    // a new entry was stored
+	touch(e *entry[K, V])  // an entry was read or overwritten
+	remove(e *entry[K, V]) // an entry left the cache
+
+	// victim returns the entry to evict next, never returning skip.
+	// It returns nil if there is no other entry.
+	victim(skip *entry[K, V]) *entry[K, V]
+}
+
+func newEvictor[K comparable, V any](p Policy) (evictor[K, V], error) {
+	switch p {
+	case LRU:
+		return &listEvictor[K, V]{l: list.New(), moveOnTouch: true}, nil
+	case FIFO:
+		return &listEvictor[K, V]{l: list.New()}, nil
+	case LFU:
+		return &lfuEvictor[K, V]{}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown policy %v", ErrInvalidOption, p)
+}
+
+// listEvictor implements LRU and FIFO, which differ only in whether a read
+// moves the entry to the front
+type listEvictor[K comparable, V any] struct {
+	l           *list.List // next victim at the back
+	moveOnTouch bool
+}
+
+func (p *listEvictor[K, V]) add(e *entry[K, V]) {
+	e.pelem = p.l.PushFront(e)
+}
+
+func (p *listEvictor[K, V]) touch(e *entry[K, V]) {
+	if p.moveOnTouch {
+		p.l.MoveToFront(e.pelem)
+	}
+}
+
+func (p *listEvictor[K, V]) remove(e *entry[K, V]) {
+	p.l.Remove(e.pelem)
+	e.pelem = nil
+}
+
+func (p *listEvictor[K, V]) victim(skip *entry[K, V]) *entry[K, V] {
+	for elem := p.l.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*entry[K, V]); e != skip {
+			return e
+		}
+	}
+	return nil
+}
+
+// lfuEvictor keeps entries in a min-heap ordered by use count, then by the
+// time of last use, so the root is always the next victim
+type lfuEvictor[K comparable, V any] struct {
+	h    lfuHeap[K, V]
+	tick uint64
+}
+
+func (p *lfuEvictor[K, V]) add(e *entry[K, V]) {
+	p.tick++
+	e.freq, e.tick = 1, p.tick
+	heap.Push(&p.h, e)
+}
+
+func (p *lfuEvictor[K, V]) touch(e *entry[K, V]) {
+	p.tick++
+	e.freq++
+	e.tick = p.tick
+	heap.Fix(&p.h, e.index)
+}
+
+func (p *lfuEvictor[K, V]) remove(e *entry[K, V]) {
+	heap.Remove(&p.h, e.index)
+}
+
+func (p *lfuEvictor[K, V]) victim(skip *entry[K, V]) *entry[K, V] {
+	if len(p.h) == 0 {
+		return nil
+	}
+	if p.h[0] != skip {
+		return p.h[0]
+	}
+	// The root is excluded, so the next victim is the smaller of its children
+	switch {
+	case len(p.h) == 1:
+		return nil
+	case len(p.h) == 2 || p.h.Less(1, 2):
+		return p.h[1]
+	}
+	return p.h[2]
+}
+
+// lfuHeap implements heap.Interface
+type lfuHeap[K comparable, V any] []*entry[K, V]
+
+func (h lfuHeap[K, V]) Len() int { return len(h) }
+
+func (h lfuHeap[K, V]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K, V]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	// Each policy sees the same accesses in a cache of 3, then one more Put
+	tests := []struct {
+		policy  Policy
+		evicted string
+	}{
+		{LRU, "key2"},  // key2 was read most but least recently
+		{FIFO, "key1"}, // key1 was written first; reads don't count
+		{LFU, "key3"},  // key2 was read most; key3 ties with key1 but is older
+	}
+	for _, tt := range tests {
+		c, err := New[string, string](WithCapacity(3), WithPolicy(tt.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Put("key1", "val1")
+		c.Put("key2", "val2")
+		c.Put("key3", "val3")
+		for _, key := range []string{"key2", "key2", "key2", "key3", "key1"} {
+			c.Get(key)
+		}
+		c.Put("key4", "val4")
+
+		for _, key := range []string{"key1", "key2", "key3", "key4"} {
+			_, err := c.Get(key)
+			if gone := errors.Is(err, ErrNotFound); gone != (key == tt.evicted) {
+				t.Errorf("%v: %s evicted = %v, want only %s evicted", tt.policy, key, gone, tt.evicted)
+			}
+		}
+	}
+}
+
+func TestLFUNeverEvictsEntryBeingWritten(t *testing.T) {
+	c, err := New[string, []byte](WithCapacity(4), WithPolicy(LFU), WithWeigher(byteWeigher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("a", make([]byte, 2))
+	c.Put("b", make([]byte, 1))
+	c.Get("b")
+	c.Get("b")
+	// a now has the lowest count; growing it must evict b instead
+	c.Put("a", make([]byte, 3))
+	c.Put("a", make([]byte, 4))
+	if _, err := c.Get("a"); err != nil {
+		t.Errorf("entry being written was evicted: %v", err)
+	}
+	if _, err := c.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("b: got %v, want ErrNotFound", err)
+	}
+}
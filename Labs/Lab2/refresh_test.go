@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// versionLoader returns "key-vN", counting loads per key. While gate is
+// non-nil, loads block until it is closed.
+type versionLoader struct {
+	mu    sync.Mutex
+	loads map[string]int
+	gate  chan struct{}
+}
+
+func (l *versionLoader) load(ctx context.Context, key string) (string, error) {
+	l.mu.Lock()
+	l.loads[key]++
+	n, gate := l.loads[key], l.gate
+	l.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	return fmt.Sprintf("%s-v%d", key, n), nil
+}
+
+func (l *versionLoader) count(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loads[key]
+}
+
+// eventually polls until lc returns want for key
+func eventually(t *testing.T, lc *LoadingCache[string, string], key, want string) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if v, _ := lc.Get(context.Background(), key); v == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%s never became %s", key, want)
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := New[string, string](WithCapacity(8), WithTTL(time.Minute), WithClock(clock))
+	loader := &versionLoader{loads: make(map[string]int)}
+	lc := NewLoadingCache[string, string](c, loader.load, WithRefreshAfter(40*time.Second))
+	ctx := context.Background()
+
+	if v, _ := lc.Get(ctx, "key"); v != "key-v1" {
+		t.Fatalf("first Get = %q", v)
+	}
+	clock.Advance(30 * time.Second)
+	lc.Get(ctx, "key")
+	if n := loader.count("key"); n != 1 {
+		t.Errorf("refreshed before the threshold: %d loads", n)
+	}
+
+	clock.Advance(15 * time.Second)
+	loader.gate = make(chan struct{})
+	if v, _ := lc.Get(ctx, "key"); v != "key-v1" {
+		t.Errorf("Get past the threshold = %q, want the current value at once", v)
+	}
+	close(loader.gate)
+	eventually(t, lc, "key", "key-v2")
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := New[string, string](WithCapacity(8), WithTTL(time.Minute), WithClock(clock))
+	loader := &versionLoader{loads: make(map[string]int)}
+	lc := NewLoadingCache[string, string](c, loader.load, WithStaleWhileRevalidate(30*time.Second))
+	ctx := context.Background()
+
+	lc.Get(ctx, "key")
+	clock.Advance(70 * time.Second) // expired, but within the grace window
+	loader.gate = make(chan struct{})
+	if v, _ := lc.Get(ctx, "key"); v != "key-v1" {
+		t.Errorf("Get within grace = %q, want the stale value", v)
+	}
+	close(loader.gate)
+	eventually(t, lc, "key", "key-v2")
+
+	clock.Advance(2 * time.Minute) // past expiry and grace: a real miss
+	loader.gate = nil
+	if v, _ := lc.Get(ctx, "key"); v != "key-v3" {
+		t.Errorf("Get past grace = %q, want a fresh load", v)
+	}
+}
+
+func TestMaxConcurrentRefreshes(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := New[string, string](WithCapacity(8), WithClock(clock))
+	loader := &versionLoader{loads: make(map[string]int)}
+	lc := NewLoadingCache[string, string](c, loader.load,
+		WithRefreshAfter(time.Second), WithMaxConcurrentRefreshes(1))
+	ctx := context.Background()
+
+	lc.Get(ctx, "a")
+	lc.Get(ctx, "b")
+	clock.Advance(time.Minute)
+	loader.gate = make(chan struct{})
+	lc.Get(ctx, "a") // starts a refresh that blocks
+	lc.Get(ctx, "b") // cap reached, served as is
+	time.Sleep(10 * time.Millisecond)
+	if n := loader.count("b"); n != 1 {
+		t.Errorf("b refreshed past the cap: %d loads", n)
+	}
+	close(loader.gate)
+	eventually(t, lc, "a", "a-v2")
+	eventually(t, lc, "b", "b-v2")
+}
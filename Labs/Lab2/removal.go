@@ -0,0 +1,42 @@
+package cache
+
+// RemovalReason says why an entry left the cache
+type RemovalReason int
+
+const (
+	ReasonCapacity RemovalReason = iota // evicted to make room for another entry
+	ReasonExpired                       // outlived its time-to-live
+	ReasonDeleted                       // removed explicitly with Delete
+	ReasonReplaced                      // overwritten by a Put of the same key
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// RemovalFunc is called when an entry leaves the cache.
+//
+// The callback runs on the goroutine that caused the removal, after the
+// cache lock has been released, so it may safely call back into the cache.
+// Each removal is reported exactly once: an entry is unlinked under the lock
+// by a single operation, and only that operation notifies. Callbacks for
+// removals made by different goroutines may run concurrently and in any
+// order.
+type RemovalFunc[K comparable, V any] func(key K, value V, reason RemovalReason)
+
+// removal is a pending callback, collected under the lock and run after it
+type removal[K comparable, V any] struct {
+	key    K
+	value  V
+	reason RemovalReason
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+type removed struct {
+	key    string
+	value  string
+	reason RemovalReason
+}
+
+func TestRemovalReasons(t *testing.T) {
+	var got []removed
+	c := NewCacher[string, string](2, WithOnRemove(func(k, v string, r RemovalReason) {
+		got = append(got, removed{k, v, r})
+	}))
+	c.Put("key1", "val1")
+	c.Put("key2", "val2")
+	c.Put("key1", "val1b") // replaces key1, which becomes most recent
+	c.Put("key3", "val3")  // evicts key2
+	c.Delete("key3")
+	c.Delete("key3") // already gone, no callback
+
+	want := []removed{
+		{"key1", "val1", ReasonReplaced},
+		{"key2", "val2", ReasonCapacity},
+		{"key3", "val3", ReasonDeleted},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d removals %v, want %v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("removal %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRemovalCallbackCanUseCache(t *testing.T) {
+	var c Cacher[string, string]
+	c = NewCacher[string, string](1, WithOnRemove(func(k, v string, r RemovalReason) {
+		// Would deadlock if the callback ran under the cache lock
+		c.Get(k)
+	}))
+	c.Put("key1", "val1")
+	c.Put("key2", "val2")
+}
+
+func TestRemovalCallbackOncePerEntry(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	c := NewCacher[string, string](8, WithOnRemove(func(k, v string, r RemovalReason) {
+		mu.Lock()
+		seen[v]++
+		mu.Unlock()
+	}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := string(rune('a' + i%16))
+				c.Put(key, key+string(rune('0'+g))+string(rune(i)))
+				c.Delete(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for v, n := range seen {
+		if n != 1 {
+			t.Errorf("value %q reported %d times", v, n)
+		}
+	}
+}
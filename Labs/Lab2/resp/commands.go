@@ -0,0 +1,285 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	cache "iru.com"
+)
+
+// Optional cache methods, all provided by *cache.Cache
+type (
+	ttlPutter interface {
+		PutWithTTL(key string, value []byte, ttl time.Duration) error
+	}
+	ttlReader interface {
+		TTL(key string) (time.Duration, bool)
+	}
+	container interface {
+		Contains(key string) bool
+	}
+	clearer interface {
+		Clear()
+	}
+)
+
+// exec runs one command and writes its reply. It reports whether the
+// client asked to close the connection.
+func (s *Server) exec(w *writer, args [][]byte) (quit bool) {
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+
+	arity := func(lo, hi int) bool {
+		if len(args) < lo || (hi >= 0 && len(args) > hi) {
+			w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+			return false
+		}
+		return true
+	}
+
+	switch name {
+	case "PING":
+		if !arity(0, 1) {
+			return
+		}
+		if len(args) == 1 {
+			w.bulk(args[0])
+		} else {
+			w.simple("PONG")
+		}
+
+	case "ECHO":
+		if arity(1, 1) {
+			w.bulk(args[0])
+		}
+
+	case "GET":
+		if !arity(1, 1) {
+			return
+		}
+		v, err := s.cache.Get(string(args[0]))
+		if err != nil {
+			w.null()
+		} else {
+			w.bulk(v)
+		}
+
+	case "SET":
+		if arity(2, 4) {
+			s.set(w, args)
+		}
+
+	case "DEL":
+		if !arity(1, -1) {
+			return
+		}
+		n := 0
+		for _, key := range args {
+			if s.cache.Delete(string(key)) {
+				n++
+			}
+		}
+		w.integer(int64(n))
+
+	case "EXISTS":
+		if !arity(1, -1) {
+			return
+		}
+		n := 0
+		for _, key := range args {
+			if s.exists(string(key)) {
+				n++
+			}
+		}
+		w.integer(int64(n))
+
+	case "TTL", "PTTL":
+		if !arity(1, 1) {
+			return
+		}
+		unit := time.Second
+		if name == "PTTL" {
+			unit = time.Millisecond
+		}
+		w.integer(s.ttl(string(args[0]), unit))
+
+	case "DBSIZE":
+		if arity(0, 0) {
+			w.integer(int64(s.cache.Len()))
+		}
+
+	case "FLUSHALL", "FLUSHDB":
+		// ASYNC and SYNC are accepted and both flush synchronously
+		if !arity(0, 1) {
+			return
+		}
+		c, ok := s.cache.(clearer)
+		if !ok {
+			w.error("ERR " + name + " is not supported by this cache")
+			return
+		}
+		c.Clear()
+		w.simple("OK")
+
+	case "INFO":
+		if arity(0, -1) {
+			w.bulk(s.info())
+		}
+
+	case "SELECT":
+		if !arity(1, 1) {
+			return
+		}
+		if string(args[0]) != "0" {
+			w.error("ERR DB index is out of range")
+			return
+		}
+		w.simple("OK")
+
+	case "CLIENT":
+		// Clients announce their name and library; nothing to record
+		if arity(1, -1) {
+			w.simple("OK")
+		}
+
+	case "COMMAND":
+		// redis-cli asks for command docs on startup; an empty list is fine
+		w.arrayLen(0)
+
+	case "QUIT":
+		w.simple("OK")
+		return true
+
+	default:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+	return false
+}
+
+// set runs SET key value [EX seconds | PX milliseconds]
+func (s *Server) set(w *writer, args [][]byte) {
+	key, value := string(args[0]), bytes.Clone(args[1])
+
+	var ttl time.Duration
+	if len(args) > 2 {
+		if len(args) != 4 {
+			w.error("ERR syntax error")
+			return
+		}
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		var unit time.Duration
+		switch strings.ToUpper(string(args[2])) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+		if n <= 0 || n > math.MaxInt64/int64(unit) {
+			w.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		ttl = time.Duration(n) * unit
+	}
+
+	var err error
+	if ttl > 0 {
+		p, ok := s.cache.(ttlPutter)
+		if !ok {
+			w.error("ERR expiry is not supported by this cache")
+			return
+		}
+		err = p.PutWithTTL(key, value, ttl)
+	} else {
+		err = s.cache.Put(key, value)
+	}
+
+	switch {
+	case errors.Is(err, cache.ErrTooHeavy):
+		w.error("ERR value is too large for the cache")
+	case err != nil:
+		w.error("ERR " + err.Error())
+	default:
+		w.simple("OK")
+	}
+}
+
+func (s *Server) exists(key string) bool {
+	if c, ok := s.cache.(container); ok {
+		return c.Contains(key)
+	}
+	_, err := s.cache.Get(key)
+	return err == nil
+}
+
+// ttl follows Redis: -2 for a missing key, -1 for one with no expiry,
+// otherwise the time left rounded to the nearest unit
+func (s *Server) ttl(key string, unit time.Duration) int64 {
+	r, ok := s.cache.(ttlReader)
+	if !ok {
+		if s.exists(key) {
+			return -1
+		}
+		return -2
+	}
+	d, ok := r.TTL(key)
+	switch {
+	case !ok:
+		return -2
+	case d == 0:
+		return -1
+	}
+	return int64((d + unit/2) / unit)
+}
+
+// info builds the reply to INFO in Redis's "field:value" format
+func (s *Server) info() []byte {
+	st := s.cache.Stats()
+	var b bytes.Buffer
+	section := func(name string) { fmt.Fprintf(&b, "# %s\r\n", name) }
+	field := func(name string, value any) { fmt.Fprintf(&b, "%s:%v\r\n", name, value) }
+
+	section("Server")
+	field("redis_version", "7.0.0")
+	field("redis_mode", "standalone")
+	field("server_name", "iru-cache")
+	field("go_version", runtime.Version())
+	field("uptime_in_seconds", int64(time.Since(s.started).Seconds()))
+	b.WriteString("\r\n")
+
+	section("Clients")
+	field("connected_clients", s.numConns())
+	field("maxclients", s.opts.MaxConns)
+	b.WriteString("\r\n")
+
+	section("Memory")
+	field("used_memory", st.Weight)
+	b.WriteString("\r\n")
+
+	section("Stats")
+	field("total_connections_received", s.totalConns.Load())
+	field("total_commands_processed", s.totalCommands.Load())
+	field("keyspace_hits", st.Hits)
+	field("keyspace_misses", st.Misses)
+	field("evicted_keys", st.Removals[cache.ReasonCapacity])
+	field("expired_keys", st.Removals[cache.ReasonExpired])
+	b.WriteString("\r\n")
+
+	section("Keyspace")
+	if st.Entries > 0 {
+		field("db0", fmt.Sprintf("keys=%d,expires=0,avg_ttl=0", st.Entries))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,147 @@
+// Package resp serves a cache over a subset of the Redis protocol (RESP2),
+// so that stock Redis clients in any language can share it.
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Limits on what a client may send, so one bad request cannot exhaust memory
+const (
+	maxArgs    = 1024 * 1024
+	maxBulkLen = 512 * 1024 * 1024
+	maxInline  = 64 * 1024
+	bulkChunk  = 64 * 1024 // first buffer for a bulk string, grown as data arrives
+)
+
+// errProtocol marks malformed input; the server replies and hangs up
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads one command: either a RESP array of bulk strings, as
+// sent by client libraries, or an inline line of space-separated words, as
+// typed into telnet. An empty inline line returns no arguments.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := readLine(r, maxInline)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+
+	line, err := readLine(r, maxInline)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	// The header is only a claim, so the slice grows as arguments arrive
+	args := make([][]byte, 0, min(max(n, 0), 64))
+	for i := 0; i < n; i++ {
+		line, err := readLine(r, maxInline)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// readBulk reads a bulk string of size bytes and the CRLF after it. The
+// buffer grows as the data arrives instead of being sized from the header,
+// so announcing a huge string without sending it costs the server nothing.
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	want := size + 2
+	arg := make([]byte, 0, min(want, bulkChunk))
+	for len(arg) < want {
+		if len(arg) == cap(arg) {
+			arg = slices.Grow(arg, min(want-len(arg), cap(arg)))
+		}
+		n, err := io.ReadFull(r, arg[len(arg):min(cap(arg), want)])
+		arg = arg[:len(arg)+n]
+		if err != nil {
+			return nil, err
+		}
+	}
+	if arg[size] != '\r' || arg[size+1] != '\n' {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+	}
+	return arg[:size], nil
+}
+
+// readLine reads a line ending in CRLF or LF and returns it without the
+// line ending
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return nil, fmt.Errorf("%w: line too long", errProtocol)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// writer encodes replies. Errors are sticky: once a write fails every
+// later call does nothing, and Flush reports the failure.
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) write(parts ...[]byte) {
+	for _, p := range parts {
+		if w.err != nil {
+			return
+		}
+		_, w.err = w.w.Write(p)
+	}
+}
+
+var crlf = []byte("\r\n")
+
+func (w *writer) simple(s string) { w.write([]byte("+"+s), crlf) }
+func (w *writer) error(s string)  { w.write([]byte("-"+s), crlf) }
+func (w *writer) integer(n int64) { w.write([]byte(":"+strconv.FormatInt(n, 10)), crlf) }
+func (w *writer) null()           { w.write([]byte("$-1"), crlf) }
+func (w *writer) arrayLen(n int)  { w.write([]byte("*"+strconv.Itoa(n)), crlf) }
+
+func (w *writer) bulk(b []byte) {
+	w.write([]byte("$"+strconv.Itoa(len(b))), crlf, b, crlf)
+}
+
+func (w *writer) flush() error {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
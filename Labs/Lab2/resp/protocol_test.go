@@ -0,0 +1,55 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadCommandBulk(t *testing.T) {
+	big := strings.Repeat("x", 3*bulkChunk+5)
+	in := encode("SET", "key", big) + encode("GET", "key")
+	r := bufio.NewReader(strings.NewReader(in))
+
+	args, err := readCommand(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 3 || string(args[2]) != big {
+		t.Fatalf("read %d args, value of %d bytes", len(args), len(args[len(args)-1]))
+	}
+	if args, err = readCommand(r); err != nil || string(bytes.Join(args, []byte(" "))) != "GET key" {
+		t.Errorf("next command = %q, %v", args, err)
+	}
+}
+
+func TestReadCommandBadTerminator(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*1\r\n$3\r\nGETxx"))
+	if _, err := readCommand(r); !errors.Is(err, errProtocol) {
+		t.Errorf("got %v, want a protocol error", err)
+	}
+}
+
+func TestReadCommandHugeHeaders(t *testing.T) {
+	// A client announces the most arguments or nearly the largest bulk
+	// string allowed, then sends only a few bytes; reading must not
+	// allocate the announced size
+	for _, in := range []string{"*1\r\n$500000000\r\nabc", "*1048576\r\n$3\r\nabc"} {
+		r := bufio.NewReader(strings.NewReader(in))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := readCommand(r)
+		runtime.ReadMemStats(&after)
+
+		if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			t.Errorf("%q: got %v, want an unexpected EOF", in, err)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("%q: allocated %d bytes for a few bytes of input", in, n)
+		}
+	}
+}
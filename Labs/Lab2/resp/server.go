@@ -0,0 +1,230 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cache "iru.com"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Options tunes a Server. Zero fields take defaults.
+type Options struct {
+	MaxConns    int           // concurrent connections, default 10000; more are refused with an error
+	IdleTimeout time.Duration // close connections idle this long, default never
+	Logger      *log.Logger   // default log.Default()
+}
+
+// Server exposes a cache to Redis clients. The commands it understands are
+// GET, SET (with EX or PX), DEL, EXISTS, TTL, DBSIZE, FLUSHALL, INFO and
+// PING, plus the few connection commands clients send on their own.
+//
+// SET with an expiry, TTL, EXISTS and FLUSHALL use the extra methods of
+// *cache.Cache when the cache has them; with a plain Cacher, EXISTS falls
+// back to Get, TTL reports no expiry and the other two reply with an error.
+type Server struct {
+	cache   cache.Cacher[string, []byte]
+	opts    Options
+	started time.Time
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closing   atomic.Bool
+	wg        sync.WaitGroup // one per connection
+
+	totalConns    atomic.Uint64
+	totalCommands atomic.Uint64
+}
+
+// conn is one client connection
+type conn struct {
+	nc   net.Conn
+	idle atomic.Bool // waiting for the next command rather than running one
+}
+
+// NewServer returns a server for c. Start it with Serve or ListenAndServe.
+func NewServer(c cache.Cacher[string, []byte], opts Options) *Server {
+	if opts.MaxConns <= 0 {
+		opts.MaxConns = 10000
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	return &Server{
+		cache:     c,
+		opts:      opts,
+		started:   time.Now(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Shutdown is called, when it
+// returns ErrServerClosed, or until l is closed by someone else. Other
+// accept errors, such as running out of file descriptors, are logged and
+// retried with a backoff. It closes l before returning.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closing.Load() {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	var backoff time.Duration
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.closing.Load() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			// Out of file descriptors or similar; wait for it to clear up
+			backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+			s.opts.Logger.Printf("resp: accept error: %v; retrying in %v", err, backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		s.totalConns.Add(1)
+
+		c := &conn{nc: nc}
+		s.mu.Lock()
+		if len(s.conns) >= s.opts.MaxConns {
+			s.mu.Unlock()
+			nc.Write([]byte("-ERR max number of clients reached\r\n"))
+			nc.Close()
+			continue
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(c)
+	}
+}
+
+// Shutdown stops the server gracefully. It closes the listeners, lets each
+// connection finish the command it is running, then closes it. If ctx ends
+// first, the remaining connections are closed at once and ctx.Err() is
+// returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing.Store(true)
+	for l := range s.listeners {
+		l.Close()
+	}
+	// Wake connections blocked waiting for a command. Busy ones see the
+	// closing flag as soon as their current command is done.
+	for c := range s.conns {
+		if c.idle.Load() {
+			c.nc.SetReadDeadline(time.Now())
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.nc.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// serveConn reads and runs commands until the client leaves or the server
+// shuts down. Replies to pipelined commands are buffered and written
+// together once no more input is waiting.
+func (s *Server) serveConn(c *conn) {
+	defer func() {
+		c.nc.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(c.nc)
+	w := &writer{w: bufio.NewWriter(c.nc)}
+	for {
+		if r.Buffered() == 0 {
+			if w.flush() != nil {
+				return
+			}
+			if s.opts.IdleTimeout > 0 {
+				c.nc.SetReadDeadline(time.Now().Add(s.opts.IdleTimeout))
+			}
+			c.idle.Store(true)
+		}
+		// Checked after marking the connection idle, so that Shutdown
+		// either sees it idle and wakes it, or it sees Shutdown here
+		if s.closing.Load() {
+			w.flush()
+			return
+		}
+
+		args, err := readCommand(r)
+		c.idle.Store(false)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR Protocol error: " + err.Error())
+				w.flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.totalCommands.Add(1)
+		if quit := s.exec(w, args); quit {
+			w.flush()
+			return
+		}
+	}
+}
+
+// numConns returns the number of open connections
+func (s *Server) numConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
@@ -0,0 +1,245 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	cache "iru.com"
+)
+
+// startServer runs a server on a free local port
+func startServer(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+	c, err := cache.New[string, []byte](cache.WithCapacity(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c, opts)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s, l.Addr().String()
+}
+
+// client speaks just enough RESP to test the server
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// encode builds a RESP array of bulk strings
+func encode(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return b.String()
+}
+
+func (c *client) send(t *testing.T, raw string) {
+	t.Helper()
+	if _, err := c.conn.Write([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reply reads one reply and renders it as a single line, with bulk
+// strings shown by their contents and arrays by their length
+func (c *client) reply(t *testing.T) string {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line[0] != '$' || line == "$-1" {
+		return line
+	}
+	var n int
+	fmt.Sscanf(line, "$%d", &n)
+	buf := make([]byte, n+2)
+	if _, err := c.r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	t.Helper()
+	c.send(t, encode(args...))
+	return c.reply(t)
+}
+
+func TestCommands(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"SET", "k1", "v1"}, "+OK"},
+		{[]string{"GET", "k1"}, "v1"},
+		{[]string{"GET", "nope"}, "$-1"},
+		{[]string{"SET", "k2", "v2", "EX", "100"}, "+OK"},
+		{[]string{"TTL", "k2"}, ":100"},
+		{[]string{"TTL", "k1"}, ":-1"},
+		{[]string{"TTL", "nope"}, ":-2"},
+		{[]string{"EXISTS", "k1", "k2", "nope"}, ":2"},
+		{[]string{"DBSIZE"}, ":2"},
+		{[]string{"DEL", "k1", "nope"}, ":1"},
+		{[]string{"SET", "k3", "v3", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k3", "v3", "EX", "9223372037"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k3", "v3", "PX", "9223372036854775807"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"SET", "k3", "v3", "NX"}, "-ERR syntax error"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"FLUSHALL"}, "+OK"},
+		{[]string{"DBSIZE"}, ":0"},
+		{[]string{"NOSUCH"}, "-ERR unknown command 'nosuch'"},
+	}
+	for _, step := range steps {
+		if got := c.do(t, step.args...); got != step.want {
+			t.Errorf("%v: got %q, want %q", step.args, got, step.want)
+		}
+	}
+
+	if info := c.do(t, "INFO"); !strings.Contains(info, "keyspace_hits:1") {
+		t.Errorf("INFO does not report the hit:\n%s", info)
+	}
+}
+
+func TestInlineCommands(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+	c.send(t, "SET greeting hello\r\nGET greeting\r\n")
+	if got := c.reply(t); got != "+OK" {
+		t.Errorf("inline SET: got %q", got)
+	}
+	if got := c.reply(t); got != "hello" {
+		t.Errorf("inline GET: got %q", got)
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	_, addr := startServer(t, Options{})
+	c := dial(t, addr)
+
+	var batch strings.Builder
+	for i := 0; i < 100; i++ {
+		batch.WriteString(encode("SET", fmt.Sprint("key", i), fmt.Sprint(i)))
+		batch.WriteString(encode("GET", fmt.Sprint("key", i)))
+	}
+	c.send(t, batch.String())
+	for i := 0; i < 100; i++ {
+		if got := c.reply(t); got != "+OK" {
+			t.Fatalf("SET %d: got %q", i, got)
+		}
+		if got := c.reply(t); got != fmt.Sprint(i) {
+			t.Fatalf("GET %d: got %q", i, got)
+		}
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	_, addr := startServer(t, Options{MaxConns: 1})
+	first := dial(t, addr)
+	if got := first.do(t, "PING"); got != "+PONG" {
+		t.Fatalf("first client: got %q", got)
+	}
+	second := dial(t, addr)
+	if got := second.reply(t); got != "-ERR max number of clients reached" {
+		t.Errorf("second client: got %q", got)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s, addr := startServer(t, Options{})
+	c := dial(t, addr)
+	if got := c.do(t, "PING"); got != "+PONG" {
+		t.Fatal(got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	// The idle client has been disconnected and no one else can connect
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("idle connection still open after Shutdown")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("listener still accepting after Shutdown")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve after Shutdown: got %v", err)
+	}
+}
+
+// flakyListener fails its first Accepts the way a process out of file
+// descriptors does, then accepts real connections
+type flakyListener struct {
+	net.Listener
+	failures atomic.Int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures.Add(-1) >= 0 {
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE}
+	}
+	return l.Listener.Accept()
+}
+
+func TestAcceptErrorsAreRetried(t *testing.T) {
+	c, _ := cache.New[string, []byte](cache.WithCapacity(100))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl := &flakyListener{Listener: l}
+	fl.failures.Store(3)
+	s := NewServer(c, Options{Logger: log.New(io.Discard, "", 0)})
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(fl) }()
+
+	if got := dial(t, l.Addr().String()).do(t, "PING"); got != "+PONG" {
+		t.Errorf("PING after accept errors = %q", got)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Codec turns snapshot records into bytes and back. GobCodec and
+// JSONCodec are provided; both need keys and values the underlying
+// encoding can handle (exported struct fields, registered interface types).
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes one value at a time to a stream
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads one value at a time from a stream
+type Decoder interface {
+	Decode(v any) error
+}
+
+var (
+	GobCodec  Codec = gobCodec{}
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// snapshotVersion is bumped whenever the record layout changes
+const snapshotVersion = 1
+
+// snapshotHeader is the first record of a snapshot
+type snapshotHeader struct {
+	Version int
+	Taken   time.Time
+	Count   int // number of entry records that follow
+}
+
+// snapshotEntry is one cached entry. Entries are written least recently
+// used first, so restoring them in order rebuilds the same recency.
+type snapshotEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	TTL   time.Duration // time left when the snapshot was taken, 0 if none
+}
+
+// WriteSnapshot writes every live entry to w, with its recency order and
+// remaining TTL. The cache is locked only while the entries are copied,
+// not while they are encoded. Policy state such as LFU counts is not kept,
+// and neither are tags or pins.
+func (c *Cache[K, V]) WriteSnapshot(w io.Writer, codec Codec) error {
+	c.mu.Lock()
+	now := c.clock.Now()
+	entries := make([]snapshotEntry[K, V], 0, len(c.cache))
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*entry[K, V])
+		if e.expiredAt(now) {
+			continue
+		}
+		se := snapshotEntry[K, V]{Key: e.key, Value: e.value}
+		if !e.expires.IsZero() {
+			se.TTL = e.expires.Sub(now)
+		}
+		entries = append(entries, se)
+	}
+	c.mu.Unlock()
+
+	enc := codec.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Taken: now, Count: len(entries)}); err != nil {
+		return fmt.Errorf("cache: writing snapshot header: %w", err)
+	}
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("cache: writing snapshot entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ReadSnapshot adds the entries of a snapshot written by WriteSnapshot and
+// returns how many it restored. TTLs keep counting while the snapshot sits
+// on disk: the time since it was taken is taken off each one, and entries
+// that expired in the meantime are not restored. Entries this cache cannot
+// take, because they are too heavy, the weigher rejects them or pinned
+// entries leave no room, are skipped. A truncated snapshot is an error,
+// but the entries read before the damage are kept.
+func (c *Cache[K, V]) ReadSnapshot(r io.Reader, codec Codec) (int, error) {
+	dec := codec.NewDecoder(r)
+	var h snapshotHeader
+	if err := dec.Decode(&h); err != nil {
+		return 0, fmt.Errorf("cache: reading snapshot header: %w", err)
+	}
+	if h.Version != snapshotVersion {
+		return 0, fmt.Errorf("cache: snapshot version %d, want %d", h.Version, snapshotVersion)
+	}
+	// A clock that has gone backwards since the snapshot counts as no time
+	elapsed := max(c.clock.Now().Sub(h.Taken), 0)
+
+	restored := 0
+	for i := 0; i < h.Count; i++ {
+		var se snapshotEntry[K, V]
+		if err := dec.Decode(&se); err != nil {
+			return restored, fmt.Errorf("cache: reading snapshot entry %d of %d: %w", i, h.Count, err)
+		}
+		if se.TTL > 0 {
+			if se.TTL <= elapsed {
+				continue
+			}
+			se.TTL -= elapsed
+		}
+		err := c.PutWithTTL(se.Key, se.Value, se.TTL)
+		if errors.Is(err, ErrTooHeavy) || errors.Is(err, ErrFull) || errors.Is(err, ErrInvalidWeight) {
+			continue
+		}
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// SaveSnapshot writes a snapshot to path atomically: it is written to a
+// temporary file in the same directory, synced, and renamed over path, so
+// a crash leaves either the old snapshot or the new one, never a mix.
+func (c *Cache[K, V]) SaveSnapshot(path string, codec Codec) (err error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cache: saving snapshot: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := c.WriteSnapshot(f, codec); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cache: saving snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cache: saving snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("cache: saving snapshot: %w", err)
+	}
+	// Sync the directory so the rename itself survives a crash. Not every
+	// platform can open a directory for syncing, so this is best effort.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// LoadSnapshot restores a snapshot saved by SaveSnapshot. On a first start
+// there is no snapshot yet; the error then matches fs.ErrNotExist.
+func (c *Cache[K, V]) LoadSnapshot(path string, codec Codec) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("cache: loading snapshot: %w", err)
+	}
+	defer f.Close()
+	return c.ReadSnapshot(f, codec)
+}
+
+// StartSnapshots saves a snapshot to path every interval, or every minute
+// if interval is not positive, until the returned stop function is
+// called. Failed saves are passed to onError, which may be nil. stop
+// saves one last snapshot and returns its error, so it belongs in the
+// shutdown path; calling it again does nothing.
+func (c *Cache[K, V]) StartSnapshots(path string, codec Codec, interval time.Duration, onError func(error)) (stop func() error) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.SaveSnapshot(path, codec); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			close(quit)
+			<-done
+			err = c.SaveSnapshot(path, codec)
+		})
+		return err
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{GobCodec, JSONCodec} {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		src, _ := New[string, int](WithCapacity(4), WithClock(clock))
+		src.PutWithTTL("a", 1, time.Minute)
+		src.Put("b", 2)
+		src.PutWithTTL("gone", 0, time.Second)
+		src.Put("c", 3)
+		src.Get("b") // recency is now b, c, gone, a
+
+		clock.Advance(2 * time.Second) // gone has expired
+		var buf bytes.Buffer
+		if err := src.WriteSnapshot(&buf, codec); err != nil {
+			t.Fatal(err)
+		}
+
+		dst, _ := New[string, int](WithCapacity(3), WithClock(clock))
+		n, err := dst.ReadSnapshot(&buf, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("%T: restored %d entries, want 3", codec, n)
+		}
+		// Recency survives: adding one more evicts a, not b
+		dst.Put("d", 4)
+		if _, err := dst.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%T: a should have been evicted first", codec)
+		}
+		if v, err := dst.Get("b"); err != nil || v != 2 {
+			t.Errorf("%T: Get(b) = %v, %v", codec, v, err)
+		}
+	}
+}
+
+func TestSnapshotKeepsRemainingTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	src, _ := New[string, string](WithCapacity(2), WithClock(clock))
+	src.PutWithTTL("key", "val", time.Minute)
+	clock.Advance(40 * time.Second)
+
+	var buf bytes.Buffer
+	src.WriteSnapshot(&buf, GobCodec)
+	dst, _ := New[string, string](WithCapacity(2), WithClock(clock))
+	dst.ReadSnapshot(&buf, GobCodec)
+
+	clock.Advance(19 * time.Second)
+	if _, err := dst.Get("key"); err != nil {
+		t.Errorf("key expired early: %v", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := dst.Get("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("key outlived its TTL: %v", err)
+	}
+}
+
+func TestSnapshotCountsDowntime(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	src, _ := New[string, string](WithCapacity(4), WithClock(clock))
+	src.PutWithTTL("short", "1", time.Minute)
+	src.PutWithTTL("long", "2", 2*time.Hour)
+	src.Put("forever", "3")
+	var buf bytes.Buffer
+	src.WriteSnapshot(&buf, GobCodec)
+
+	// Restored an hour later: short expired while the cache was down
+	clock.Advance(time.Hour)
+	dst, _ := New[string, string](WithCapacity(4), WithClock(clock))
+	n, err := dst.ReadSnapshot(&buf, GobCodec)
+	if err != nil || n != 2 {
+		t.Fatalf("ReadSnapshot = %d, %v; want 2 entries", n, err)
+	}
+	if _, err := dst.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("short came back to life: %v", err)
+	}
+	if ttl, ok := dst.TTL("long"); !ok || ttl != time.Hour {
+		t.Errorf("TTL(long) = %v, %v; want 1h", ttl, ok)
+	}
+	if ttl, ok := dst.TTL("forever"); !ok || ttl != 0 {
+		t.Errorf("TTL(forever) = %v, %v; want no expiry", ttl, ok)
+	}
+}
+
+func TestSnapshotSkipsEntriesThatDoNotFit(t *testing.T) {
+	src, _ := New[string, string](WithCapacity(4))
+	src.Put("a", "1")
+	src.Put("b", "2")
+	var buf bytes.Buffer
+	src.WriteSnapshot(&buf, GobCodec)
+
+	// The only slot is pinned, so neither entry fits; the restore goes on
+	dst, _ := New[string, string](WithCapacity(1))
+	dst.Put("pinned", "x")
+	dst.Pin("pinned")
+	n, err := dst.ReadSnapshot(&buf, GobCodec)
+	if err != nil || n != 0 {
+		t.Errorf("ReadSnapshot = %d, %v; want 0 entries and no error", n, err)
+	}
+}
+
+func TestSnapshotTruncated(t *testing.T) {
+	src, _ := New[string, string](WithCapacity(4))
+	src.Put("a", "1")
+	src.Put("b", "2")
+	var buf bytes.Buffer
+	src.WriteSnapshot(&buf, JSONCodec)
+
+	short := buf.Bytes()[:buf.Len()-5]
+	dst, _ := New[string, string](WithCapacity(4))
+	if _, err := dst.ReadSnapshot(bytes.NewReader(short), JSONCodec); err == nil {
+		t.Error("truncated snapshot read without error")
+	}
+}
+
+func TestSaveAndLoadSnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+
+	c, _ := New[string, string](WithCapacity(4))
+	if _, err := c.LoadSnapshot(path, GobCodec); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("loading a missing snapshot: got %v, want fs.ErrNotExist", err)
+	}
+
+	stop := c.StartSnapshots(path, GobCodec, time.Hour, nil)
+	c.Put("key", "val")
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("snapshot dir has %d files, want only the snapshot", len(files))
+	}
+
+	restored, _ := New[string, string](WithCapacity(4))
+	if n, err := restored.LoadSnapshot(path, GobCodec); err != nil || n != 1 {
+		t.Fatalf("LoadSnapshot = %d, %v", n, err)
+	}
+	if v, _ := restored.Get("key"); v != "val" {
+		t.Errorf("Get(key) = %q, want val", v)
+	}
+}
+
+func TestStartSnapshotsZeroInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	c, _ := New[string, string](WithCapacity(4))
+	// A non-positive interval falls back to the default instead of
+	// panicking in the snapshot goroutine
+	stop := c.StartSnapshots(path, GobCodec, 0, nil)
+	time.Sleep(10 * time.Millisecond)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// numReasons is the number of RemovalReason values
+const numReasons = int(ReasonReplaced) + 1
+
+// Stats is a point-in-time snapshot of a cache's counters.
+// Counters only ever grow; subtract two snapshots to get a rate.
+type Stats struct {
+	Hits   uint64 // Get calls that found the key
+	Misses uint64 // Get calls that did not
+	Puts   uint64 // successful Put calls
+
+	// NegativeHits counts Get calls answered from the negative cache.
+	// They are neither hits nor misses.
+	NegativeHits uint64
+
+	// Removals counts entries that left the cache, indexed by RemovalReason.
+	// Removals[ReasonCapacity] is the number of evictions.
+	Removals [numReasons]uint64
+
+	LoadSuccesses uint64        // loader calls that returned a value
+	LoadErrors    uint64        // loader calls that returned an error
+	LoadTime      time.Duration // total time spent in loader calls
+
+	Entries int   // entries in the cache when the snapshot was taken
+	Weight  int64 // their total weight
+
+	NegativeEntries int // cached negative results, not counted in Entries
+
+	PinnedEntries int   // entries among Entries that are pinned
+	PinnedWeight  int64 // their total weight, included in Weight
+}
+
+// Requests returns the number of Get calls
+func (s Stats) Requests() uint64 {
+	return s.Hits + s.Misses + s.NegativeHits
+}
+
+// HitRatio returns the fraction of Get calls that were hits, or 0 before
+// the first Get
+func (s Stats) HitRatio() float64 {
+	if s.Requests() == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Requests())
+}
+
+// Loads returns the number of loader calls
+func (s Stats) Loads() uint64 {
+	return s.LoadSuccesses + s.LoadErrors
+}
+
+// AverageLoadTime returns the mean loader latency, or 0 before the first load
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads() == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads())
+}
+
+// statsCounter holds the live counters behind Stats. Each cache owns one
+// and updates it with atomic adds, so recording costs no extra locking and
+// taking a snapshot never blocks readers or writers.
+type statsCounter struct {
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+	puts     atomic.Uint64
+	removals [numReasons]atomic.Uint64
+
+	loadSuccesses atomic.Uint64
+	loadErrors    atomic.Uint64
+	loadNanos     atomic.Int64
+}
+
+// recordLoad counts one loader call, for caches that fetch missing values
+func (s *statsCounter) recordLoad(d time.Duration, err error) {
+	if err != nil {
+		s.loadErrors.Add(1)
+	} else {
+		s.loadSuccesses.Add(1)
+	}
+	s.loadNanos.Add(int64(d))
+}
+
+func (s *statsCounter) snapshot() Stats {
+	st := Stats{
+		Hits:          s.hits.Load(),
+		Misses:        s.misses.Load(),
+		Puts:          s.puts.Load(),
+		LoadSuccesses: s.loadSuccesses.Load(),
+		LoadErrors:    s.loadErrors.Load(),
+		LoadTime:      time.Duration(s.loadNanos.Load()),
+	}
+	for i := range s.removals {
+		st.Removals[i] = s.removals[i].Load()
+	}
+	return st
+}
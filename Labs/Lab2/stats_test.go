@@ -0,0 +1,30 @@
+package cache
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	c := NewCacher[string, string](2)
+	c.Put("key1", "val1")
+	c.Put("key2", "val2")
+	c.Put("key2", "val2b") // replaced
+	c.Put("key3", "val3")  // evicts key1
+	c.Get("key1")
+	c.Get("key2")
+	c.Get("key3")
+	c.Delete("key3")
+
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 1 || s.Puts != 4 {
+		t.Errorf("hits/misses/puts = %d/%d/%d, want 2/1/4", s.Hits, s.Misses, s.Puts)
+	}
+	want := [numReasons]uint64{ReasonCapacity: 1, ReasonDeleted: 1, ReasonReplaced: 1}
+	if s.Removals != want {
+		t.Errorf("Removals = %v, want %v", s.Removals, want)
+	}
+	if s.Entries != 1 || s.Weight != 1 {
+		t.Errorf("Entries/Weight = %d/%d, want 1/1", s.Entries, s.Weight)
+	}
+	if r := s.HitRatio(); r < 0.66 || r > 0.67 {
+		t.Errorf("HitRatio() = %v, want 2/3", r)
+	}
+}
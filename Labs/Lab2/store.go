@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Store is a persistent backing store that a cache sits in front of, such
+// as a database table. Load has the same shape as Loader, so a store can
+// also fill misses: NewLoadingCache(c, store.Load).
+type Store[K comparable, V any] interface {
+	Load(ctx context.Context, key K) (V, error)
+	Save(ctx context.Context, key K, value V) error
+	Delete(ctx context.Context, key K) error
+}
+
+// BatchStore is a Store that can save many entries in one round trip.
+// Write-behind caches use SaveBatch when the store provides it.
+type BatchStore[K comparable, V any] interface {
+	Store[K, V]
+	SaveBatch(ctx context.Context, entries map[K]V) error
+}
+
+// WriteThroughCache persists every write to a Store before caching it,
+// so the cache never holds a value the store does not have. Writes to the
+// same key are serialised, so concurrent writers leave the cache and the
+// store with the same value; writes to different keys run in parallel.
+type WriteThroughCache[K comparable, V any] struct {
+	Cacher[K, V]
+	store Store[K, V]
+	locks keyLocks[K]
+}
+
+// NewWriteThrough wraps c so that writes go to store first
+func NewWriteThrough[K comparable, V any](c Cacher[K, V], store Store[K, V]) *WriteThroughCache[K, V] {
+	return &WriteThroughCache[K, V]{Cacher: c, store: store, locks: keyLocks[K]{held: make(map[K]*keyLock)}}
+}
+
+// Put saves the value to the store and then caches it. If the store fails
+// the cache is left unchanged.
+func (c *WriteThroughCache[K, V]) Put(key K, value V) error {
+	return c.PutContext(context.Background(), key, value)
+}
+
+// PutContext is Put with a context for the store call
+func (c *WriteThroughCache[K, V]) PutContext(ctx context.Context, key K, value V) error {
+	defer c.locks.lock(key)()
+	if err := c.store.Save(ctx, key, value); err != nil {
+		return fmt.Errorf("cache: write-through save of key %v: %w", key, err)
+	}
+	return c.Cacher.Put(key, value)
+}
+
+// Delete removes the key from the store and then from the cache. It
+// reports whether the key was cached; if the store fails it returns false
+// and leaves the cache alone.
+func (c *WriteThroughCache[K, V]) Delete(key K) bool {
+	ok, _ := c.DeleteContext(context.Background(), key)
+	return ok
+}
+
+// DeleteContext is Delete with a context for the store call, returning
+// the store's error
+func (c *WriteThroughCache[K, V]) DeleteContext(ctx context.Context, key K) (bool, error) {
+	defer c.locks.lock(key)()
+	if err := c.store.Delete(ctx, key); err != nil {
+		return false, fmt.Errorf("cache: write-through delete of key %v: %w", key, err)
+	}
+	return c.Cacher.Delete(key), nil
+}
+
+// keyLocks is a mutex per key, made on demand and dropped once no one
+// holds or waits for it
+type keyLocks[K comparable] struct {
+	mu   sync.Mutex
+	held map[K]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int // holders and waiters
+}
+
+// lock locks key and returns the function that unlocks it
+func (l *keyLocks[K]) lock(key K) (unlock func()) {
+	l.mu.Lock()
+	kl, ok := l.held[key]
+	if !ok {
+		kl = &keyLock{}
+		l.held[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	kl.Lock()
+	return func() {
+		kl.Unlock()
+		l.mu.Lock()
+		if kl.refs--; kl.refs == 0 {
+			delete(l.held, key)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory Store that counts its calls
+type memStore struct {
+	mu      sync.Mutex
+	data    map[string]string
+	saves   int
+	batches int
+	fail    error
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string)}
+}
+
+func (s *memStore) Load(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		return "", errors.New("not in store")
+	}
+	return v, nil
+}
+
+func (s *memStore) Save(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		return s.fail
+	}
+	s.saves++
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		return s.fail
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memStore) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+// batchStore adds SaveBatch to memStore
+type batchStore struct{ *memStore }
+
+func (s batchStore) SaveBatch(ctx context.Context, entries map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches++
+	for k, v := range entries {
+		s.data[k] = v
+	}
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := newMemStore()
+	c := NewWriteThrough(NewCacher[string, string](4), store)
+
+	if err := c.Put("key1", "val1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.get("key1"); !ok || v != "val1" {
+		t.Errorf("store has %q, %v; want val1", v, ok)
+	}
+
+	store.fail = errors.New("disk full")
+	if err := c.Put("key2", "val2"); !errors.Is(err, store.fail) {
+		t.Errorf("got %v, want the store's error", err)
+	}
+	if _, err := c.Get("key2"); err == nil {
+		t.Error("failed write should not be cached")
+	}
+}
+
+// slowCacher delays every Put, widening the window between the store
+// write and the cache write
+type slowCacher struct{ Cacher[string, string] }
+
+func (c slowCacher) Put(key, value string) error {
+	time.Sleep(time.Duration(rand.IntN(200)) * time.Microsecond)
+	return c.Cacher.Put(key, value)
+}
+
+func TestWriteThroughConcurrentPutsAgree(t *testing.T) {
+	for round := range 200 {
+		store := newMemStore()
+		c := NewWriteThrough(slowCacher{NewCacher[string, string](4)}, store)
+
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Put("key", string(rune('a'+i)))
+			}()
+		}
+		wg.Wait()
+
+		cached, _ := c.Get("key")
+		if stored, _ := store.get("key"); cached != stored {
+			t.Fatalf("round %d: cache has %q but the store has %q", round, cached, stored)
+		}
+		if len(c.locks.held) != 0 {
+			t.Fatalf("round %d: %d key locks left behind", round, len(c.locks.held))
+		}
+	}
+}
+
+func TestWriteBehindBatchesAndFlushesOnClose(t *testing.T) {
+	store := batchStore{newMemStore()}
+	c := NewWriteBehind(NewCacher[string, string](4), store,
+		WriteBehindOptions[string]{FlushInterval: time.Hour})
+
+	c.Put("key1", "val1")
+	c.Put("key1", "val1b")
+	c.Put("key2", "val2")
+	if _, ok := store.get("key1"); ok {
+		t.Error("write reached the store before a flush")
+	}
+	if v, _ := c.Get("key1"); v != "val1b" {
+		t.Errorf("Get = %q, want val1b", v)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get("key1"); v != "val1b" {
+		t.Errorf("store has %q for key1 after Close, want val1b", v)
+	}
+	if store.batches != 1 || store.saves != 0 {
+		t.Errorf("got %d batches and %d single saves, want 1 and 0", store.batches, store.saves)
+	}
+	if err := c.Put("key3", "val3"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Put after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestWriteBehindQueueFull(t *testing.T) {
+	store := newMemStore()
+	c := NewWriteBehind(NewCacher[string, string](4), store,
+		WriteBehindOptions[string]{FlushInterval: time.Hour, MaxPending: 2, BatchSize: 2})
+	defer c.Close()
+
+	// Hold the store lock so the early flush triggered below cannot drain
+	store.mu.Lock()
+	c.Put("key1", "val1")
+	c.Put("key2", "val2")
+	time.Sleep(10 * time.Millisecond) // let the flusher take the batch and block
+	c.Put("key3", "val3")
+	c.Put("key4", "val4")
+	err := c.Put("key5", "val5")
+	store.mu.Unlock()
+
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("got %v, want ErrQueueFull", err)
+	}
+}
+
+func TestWriteBehindReportsErrors(t *testing.T) {
+	store := newMemStore()
+	store.fail = errors.New("disk full")
+	var failed []string
+	c := NewWriteBehind(NewCacher[string, string](4), store,
+		WriteBehindOptions[string]{FlushInterval: time.Hour, OnError: func(key string, err error) {
+			failed = append(failed, key)
+		}})
+	defer c.Close()
+
+	c.Put("key1", "val1")
+	if err := c.Flush(context.Background()); !errors.Is(err, store.fail) {
+		t.Errorf("Flush: got %v, want the store's error", err)
+	}
+	if len(failed) != 1 || failed[0] != "key1" {
+		t.Errorf("OnError saw %v, want [key1]", failed)
+	}
+}
+
+func TestWriteBehindConcurrentPutsAgree(t *testing.T) {
+	for round := range 200 {
+		store := newMemStore()
+		c := NewWriteBehind(NewCacher[string, string](4), store,
+			WriteBehindOptions[string]{FlushInterval: time.Hour})
+
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Put("key", string(rune('a'+i)))
+			}()
+		}
+		wg.Wait()
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		cached, _ := c.Get("key")
+		if stored, _ := store.get("key"); cached != stored {
+			t.Fatalf("round %d: cache has %q but the store has %q", round, cached, stored)
+		}
+	}
+}
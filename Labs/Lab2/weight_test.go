@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func byteWeigher(key string, value []byte) int64 { return int64(len(value)) }
+
+func TestWeightEviction(t *testing.T) {
+	c := NewCacher[string, []byte](10, WithWeigher(byteWeigher))
+	c.Put("a", make([]byte, 4))
+	c.Put("b", make([]byte, 4))
+	c.Get("a")                  // b is now least recently used
+	c.Put("c", make([]byte, 5)) // 13 > 10, evicts b
+
+	if _, err := c.Get("b"); err == nil {
+		t.Error("expected b to be evicted")
+	}
+	if got := c.Weight(); got != 9 {
+		t.Errorf("Weight() = %d, want 9", got)
+	}
+
+	c.Put("a", make([]byte, 6)) // replacing a grows it to 11, evicts c
+	if _, err := c.Get("c"); err == nil {
+		t.Error("expected c to be evicted")
+	}
+	if got, n := c.Weight(), c.Len(); got != 6 || n != 1 {
+		t.Errorf("Weight() = %d, Len() = %d, want 6 and 1", got, n)
+	}
+}
+
+func TestWeightTooHeavy(t *testing.T) {
+	c := NewCacher[string, []byte](10, WithWeigher(byteWeigher))
+	c.Put("a", make([]byte, 3))
+
+	err := c.Put("big", make([]byte, 11))
+	if !errors.Is(err, ErrTooHeavy) {
+		t.Fatalf("Put of heavy entry: got %v, want ErrTooHeavy", err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Error("rejected Put should not evict other entries")
+	}
+	if got := c.Weight(); got != 3 {
+		t.Errorf("Weight() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,267 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WriteBehindOptions tunes a write-behind cache. Zero fields take defaults.
+type WriteBehindOptions[K comparable] struct {
+	FlushInterval time.Duration // how often dirty entries are flushed, default 1s
+	MaxPending    int           // dirty keys allowed before writes fail with ErrQueueFull, default 1024
+	BatchSize     int           // dirty keys that trigger an early flush, default MaxPending/2
+
+	// OnError is told about every key whose write to the store failed.
+	// It runs on the flushing goroutine and must not block for long. The
+	// failed write is dropped; the callback may queue it again with Put.
+	OnError func(key K, err error)
+}
+
+// WriteBehindCache caches writes immediately and saves them to a Store
+// asynchronously. Several writes to the same key between flushes are
+// collapsed into one, and each flush is a single batch when the store is a
+// BatchStore.
+//
+// Close flushes whatever is still dirty, so always Close a write-behind
+// cache before exiting.
+type WriteBehindCache[K comparable, V any] struct {
+	Cacher[K, V]
+	store Store[K, V]
+	opts  WriteBehindOptions[K]
+
+	mu       sync.Mutex
+	dirty    map[K]pendingWrite[V] // waiting for the next flush
+	flushing map[K]pendingWrite[V] // being written by the current flush
+	closed   bool
+
+	kick     chan struct{}   // asks the flusher for an early flush
+	flushReq chan chan error // explicit Flush calls
+	stop     chan struct{}
+	done     chan struct{}
+	closeErr error // result of the final flush, set before done is closed
+}
+
+// pendingWrite is the latest write to a key that has not reached the store
+type pendingWrite[V any] struct {
+	value   V
+	deleted bool
+}
+
+// NewWriteBehind wraps c so that writes are saved to store in the
+// background. It starts a flusher goroutine that runs until Close.
+func NewWriteBehind[K comparable, V any](c Cacher[K, V], store Store[K, V], opts WriteBehindOptions[K]) *WriteBehindCache[K, V] {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 1024
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > opts.MaxPending {
+		opts.BatchSize = max(opts.MaxPending/2, 1)
+	}
+	wb := &WriteBehindCache[K, V]{
+		Cacher:   c,
+		store:    store,
+		opts:     opts,
+		dirty:    make(map[K]pendingWrite[V]),
+		kick:     make(chan struct{}, 1),
+		flushReq: make(chan chan error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+// Get returns the cached value, or a value still waiting to be flushed if
+// the cache has already evicted it
+func (c *WriteBehindCache[K, V]) Get(key K) (V, error) {
+	v, err := c.Cacher.Get(key)
+	if err == nil {
+		return v, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.dirty[key]; ok && !p.deleted {
+		return p.value, nil
+	}
+	if p, ok := c.flushing[key]; ok && !p.deleted {
+		return p.value, nil
+	}
+	return v, err
+}
+
+// Put caches the value and queues it for the store. It fails with
+// ErrQueueFull when MaxPending other keys are already waiting. The write
+// is queued even if the cache itself rejects the value as too heavy.
+func (c *WriteBehindCache[K, V]) Put(key K, value V) error {
+	var err error
+	if qerr := c.enqueue(key, pendingWrite[V]{value: value}, func() {
+		err = c.Cacher.Put(key, value)
+	}); qerr != nil {
+		return qerr
+	}
+	return err
+}
+
+// Delete removes the key from the cache and queues its deletion from the
+// store. It reports whether the key was cached; a full queue or closed
+// cache leaves everything unchanged and reports false.
+func (c *WriteBehindCache[K, V]) Delete(key K) bool {
+	var deleted bool
+	c.enqueue(key, pendingWrite[V]{deleted: true}, func() {
+		deleted = c.Cacher.Delete(key)
+	})
+	return deleted
+}
+
+// enqueue marks key dirty and then runs apply to update the cache, both
+// under c.mu, so that concurrent writes to one key reach the cache in the
+// same order as the store. apply is not run if the write is refused. The
+// wrapped cache must not call back into c while it runs, from an eviction
+// callback for instance.
+func (c *WriteBehindCache[K, V]) enqueue(key K, p pendingWrite[V], apply func()) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if _, ok := c.dirty[key]; !ok && len(c.dirty) >= c.opts.MaxPending {
+		c.mu.Unlock()
+		c.signal()
+		return ErrQueueFull
+	}
+	c.dirty[key] = p
+	n := len(c.dirty)
+	apply()
+	c.mu.Unlock()
+
+	if n >= c.opts.BatchSize {
+		c.signal()
+	}
+	return nil
+}
+
+// signal wakes the flusher without blocking
+func (c *WriteBehindCache[K, V]) signal() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Flush writes every dirty entry to the store now and returns the joined
+// errors of any writes that failed
+func (c *WriteBehindCache[K, V]) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case c.flushReq <- reply:
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting writes, flushes what is still dirty and stops the
+// flusher. It returns the errors of the final flush. Calling Close again
+// returns the same result.
+func (c *WriteBehindCache[K, V]) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return c.closeErr
+}
+
+// run is the flusher goroutine
+func (c *WriteBehindCache[K, V]) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.kick:
+			c.flush()
+		case reply := <-c.flushReq:
+			reply <- c.flush()
+		case <-c.stop:
+			c.closeErr = c.flush()
+			return
+		}
+	}
+}
+
+// flush writes the current dirty set to the store. Only the flusher
+// goroutine calls it, so flushes never overlap.
+func (c *WriteBehindCache[K, V]) flush() error {
+	c.mu.Lock()
+	batch := c.dirty
+	if len(batch) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	c.dirty = make(map[K]pendingWrite[V])
+	c.flushing = batch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.flushing = nil
+		c.mu.Unlock()
+	}()
+
+	ctx := context.Background()
+	var errs []error
+	fail := func(key K, err error) {
+		errs = append(errs, fmt.Errorf("key %v: %w", key, err))
+		if c.opts.OnError != nil {
+			c.opts.OnError(key, err)
+		}
+	}
+
+	saves := make(map[K]V, len(batch))
+	for key, p := range batch {
+		if p.deleted {
+			if err := c.store.Delete(ctx, key); err != nil {
+				fail(key, err)
+			}
+			continue
+		}
+		saves[key] = p.value
+	}
+
+	if bs, ok := c.store.(BatchStore[K, V]); ok && len(saves) > 0 {
+		if err := bs.SaveBatch(ctx, saves); err != nil {
+			for key := range saves {
+				fail(key, err)
+			}
+		}
+	} else {
+		for key, v := range saves {
+			if err := c.store.Save(ctx, key, v); err != nil {
+				fail(key, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("cache: write-behind flush: %w", errors.Join(errs...))
+	}
+	return nil
+}
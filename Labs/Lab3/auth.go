@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// credentialStore knows the registered users and their secrets. A secret
+// is a password or a token; the chat treats them the same.
+type credentialStore interface {
+	// registered reports whether username belongs to someone. It is called
+	// on the broadcaster goroutine, so it must be quick.
+	registered(username string) bool
+
+	// check reports whether secret is valid for username. It may be slow,
+	// and is never called on the broadcaster goroutine.
+	check(username, secret string) (bool, error)
+}
+
+// maxLoginFailures is how many wrong secrets a connection may send before
+// it is hung up on
+const maxLoginFailures = 3
+
+var (
+	errWrongSecret   = errors.New("wrong password")
+	errNotRegistered = errors.New("only registered users may join")
+)
+
+// usersFile is a credentialStore read from a file with one user per line:
+//
+//	alice:$2a$10$...
+//
+// The second field is a bcrypt hash, as printed by chat -hash. A user may
+// have several lines, such as one for a password and more for tokens
+// given to bots; any of them lets the user in. Names are matched ignoring
+// case, and blank lines and lines starting with # are skipped.
+type usersFile struct {
+	hashes map[string][][]byte // by nameKey
+}
+
+func loadUsersFile(path string) (*usersFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	u := &usersFile{hashes: make(map[string][][]byte)}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, hash, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want name:hash", path, line)
+		}
+		if err := validUsername(name); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		key := nameKey(name)
+		u.hashes[key] = append(u.hashes[key], []byte(hash))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (u *usersFile) registered(username string) bool {
+	return len(u.hashes[nameKey(username)]) > 0
+}
+
+func (u *usersFile) check(username, secret string) (bool, error) {
+	for _, hash := range u.hashes[nameKey(username)] {
+		err := bcrypt.CompareHashAndPassword(hash, []byte(secret))
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// login authenticates a client wanting to be called username. Registered
+// names need their secret, which login asks for; other names are only
+// allowed when guests are. It returns false for ok if the connection ended
+// while waiting for the secret.
+func (s *server) login(cli *client, username string, lines <-chan string) (ok bool, err error) {
+	if s.auth == nil {
+		return true, nil
+	}
+	if !s.auth.registered(username) {
+		if !s.guests {
+			return true, errNotRegistered
+		}
+		return true, nil
+	}
+
+	cli.send("Password: ")
+	secret, ok := s.readLine(cli, lines)
+	if !ok {
+		return false, nil
+	}
+	valid, err := s.auth.check(username, secret)
+	switch {
+	case err != nil:
+		return true, fmt.Errorf("cannot check passwords right now: %w", err)
+	case !valid:
+		return true, errWrongSecret
+	}
+	cli.account = username
+	return true, nil
+}
+
+// hashSecret reads a password or token from standard input and prints
+// the line to add to a users file for username
+func hashSecret(username string) error {
+	if err := validUsername(username); err != nil {
+		return err
+	}
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		return fmt.Errorf("reading the password from standard input: %w", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(strings.TrimRight(secret, "\r\n")), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s:%s\n", username, hash)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeUsers writes a users file with one line per name and secret
+func writeUsers(t *testing.T, secrets ...[2]string) string {
+	t.Helper()
+	var data []byte
+	data = append(data, "# test users\n\n"...)
+	for _, s := range secrets {
+		hash, err := bcrypt.GenerateFromPassword([]byte(s[1]), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = fmt.Appendf(data, "%s:%s\n", s[0], hash)
+	}
+	path := filepath.Join(t.TempDir(), "users")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUsersFile(t *testing.T) {
+	u, err := loadUsersFile(writeUsers(t, [2]string{"Alice", "hunter2"}, [2]string{"alice", "bot-token"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.registered("ALICE") || u.registered("bob") {
+		t.Error("registered is wrong")
+	}
+	for secret, want := range map[string]bool{"hunter2": true, "bot-token": true, "hunter3": false, "": false} {
+		if ok, err := u.check("alice", secret); ok != want || err != nil {
+			t.Errorf("check(alice, %q) = %v, %v; want %v", secret, ok, err, want)
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad")
+	for _, data := range []string{"alice\n", "alice:notahash\n", "9lives:$2a$04$abc\n"} {
+		os.WriteFile(bad, []byte(data), 0o600)
+		if _, err := loadUsersFile(bad); err == nil {
+			t.Errorf("loaded %q", data)
+		}
+	}
+}
+
+// startAuthServer runs a server where alice is registered with password
+// hunter2
+func startAuthServer(t *testing.T, guests bool) *server {
+	u, err := loadUsersFile(writeUsers(t, [2]string{"alice", "hunter2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startServerConfig(config{queueSize: 16, auth: u, guests: guests})
+}
+
+// dial connects to s and waits for the username prompt
+func dial(t *testing.T, s *server) *testClient {
+	conn, peer := pipe(t)
+	go s.handleConn(conn)
+	c := &testClient{conn: peer, r: bufio.NewReader(peer)}
+	c.expect(t, "Choose username")
+	return c
+}
+
+func (c *testClient) send(lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintln(c.conn, line)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := startAuthServer(t, false)
+	c := dial(t, s)
+
+	c.send("alice")
+	c.expect(t, "Password:")
+	c.send("wrong")
+	c.expect(t, "Sorry, wrong password")
+	c.expect(t, "Choose username")
+
+	c.send("bob")
+	c.expect(t, "only registered users may join")
+	c.expect(t, "Choose username")
+
+	c.send("Alice", "hunter2")
+	c.expect(t, "Now talking in #lobby")
+}
+
+func TestLoginFailuresHangUp(t *testing.T) {
+	c := dial(t, startAuthServer(t, false))
+	for range maxLoginFailures {
+		c.send("alice", "wrong")
+	}
+	c.expect(t, "Too many failed logins")
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.r.ReadString('\n'); err == nil || errorIsTimeout(err) {
+		t.Errorf("connection still open: %v", err)
+	}
+}
+
+func TestGuests(t *testing.T) {
+	s := startAuthServer(t, true)
+	guest := dial(t, s)
+	guest.send("bob")
+	guest.expect(t, "Now talking in #lobby")
+
+	// A guest cannot take a registered name, but its owner can
+	guest.send("/nick alice")
+	guest.expect(t, "alice is registered to someone else")
+
+	alice := dial(t, s)
+	alice.send("alice", "hunter2")
+	alice.expect(t, "Now talking in #lobby")
+	alice.send("/nick ally", "/nick ALICE")
+	alice.expect(t, "ally is now known as ALICE")
+}
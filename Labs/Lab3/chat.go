@@ -3,124 +3,379 @@
 // License: https://creativecommons.org/licenses/by-nc-sa/4.0/
 
 // Chat is a server that lets clients chat with each other.
+//
+// Clients talk in rooms. Everyone starts in #lobby; /join #room, /leave
+// [#room], /rooms and /who [#room] move between them. A client can be in
+// several rooms at once; its messages go to the one it joined last, and
+// joining a room it is already in switches to it. /msg <user> <text>
+// sends a private message to one user. Each room remembers its recent
+// messages, shows them to those who join, and /history N shows more.
+// /help lists every command; new ones are added with register.
+//
+// Every client gets a bounded outbound queue, so a client that stops
+// reading cannot hold up everyone else. What happens when its queue fills
+// up is set with -overflow. Clients that say nothing for -idle are warned
+// and then disconnected.
+//
+// With -http, browsers can join too: the page at / is a small client that
+// talks to the same broadcaster over a WebSocket at /ws.
+//
+// -tls-cert and -tls-key encrypt both. -users makes the names listed in a
+// users file need their password (or a token); everyone else is refused,
+// unless -guests lets them in under other names. Make a users file line
+// with: echo secret | chat -hash alice >> users
+//
+// -log-dir keeps every message said in a room on disk, one file per room
+// per day. /search finds messages there, and the chatlog command searches
+// the logs offline.
 
 package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"time"
 )
 
-type client struct {
-	out_channel chan<- string // sends outgoing message as strings
-	username    string        // stores client username
+// config holds the server's settings
+type config struct {
+	queueSize   int            // outbound queue length per client
+	overflow    overflowPolicy // what to do when a queue is full
+	idleTimeout time.Duration  // hang up on clients silent this long, 0 = never
+	idleWarning time.Duration  // how long before hanging up to warn them, 0 = don't
+	history     historyConfig
+
+	auth   credentialStore // nil lets anyone take any free name
+	guests bool            // with auth, let in unregistered names too
+
+	log *msgLog // nil = don't log messages
 }
 
-// Constructor function for client
-func newClient(username string, out_channel chan<- string) *client { // Returns a pointer to client struct
-	cli := client{username: username, out_channel: out_channel}
-	return &cli
+type server struct {
+	config
+
+	entering chan entry   // signals a new client entering the system
+	leaving  chan *client // signalw when a client leavs the system
+	messages chan message // all incoming client lines
+	notices  chan message // lines for one client from outside the hub, such as idle warnings
 }
 
-var (
-	entering = make(chan client) // signals a new client entering the system
-	leaving  = make(chan client) // signalw when a client leavs the system
-	messages = make(chan string) // all incoming client messages
-)
+// entry is a client asking to enter the chat under a username. The
+// broadcaster answers on result: nil, or why the name cannot be had.
+type entry struct {
+	cli      *client
+	username string
+	result   chan error
+}
+
+// message is a line typed by a client, or for one
+type message struct {
+	from *client
+	text string
+}
+
+func newServer(cfg config) *server {
+	return &server{
+		config:   cfg,
+		entering: make(chan entry),
+		leaving:  make(chan *client),
+		messages: make(chan message),
+		notices:  make(chan message),
+	}
+}
 
 // set up a tcp server
 func main() {
-	listener, err := net.Listen("tcp", "localhost:8000")
+	addr := flag.String("addr", "localhost:8000", "address to listen on")
+	httpAddr := flag.String("http", "", "address to serve the browser client on, such as localhost:8080 (empty = off)")
+	queueSize := flag.Int("queue", 64, "outgoing messages to buffer per client")
+	overflowFlag := flag.String("overflow", "drop-oldest", "when a client's queue is full: drop-oldest, drop-newest or disconnect")
+	idle := flag.Duration("idle", 30*time.Minute, "disconnect clients idle this long (0 = never)")
+	warning := flag.Duration("idle-warning", time.Minute, "warn idle clients this long before disconnecting them")
+	historySize := flag.Int("history", 100, "messages to remember per room (0 = none)")
+	historyAge := flag.Duration("history-age", time.Hour, "forget messages older than this (0 = keep until pushed out)")
+	replay := flag.Int("replay", 10, "recent messages to show on joining a room")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; with -tls-key, both listeners use TLS")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	users := flag.String("users", "", "file of registered users (name:bcrypt-hash); their names need their password")
+	guests := flag.Bool("guests", false, "with -users, let unregistered users in under unregistered names")
+	hash := flag.String("hash", "", "print a users file line for this name, with the password read from standard input, and exit")
+	logDir := flag.String("log-dir", "", "directory to log messages to, one subdirectory per room (empty = off)")
+	logMaxSize := flag.Int64("log-max-size", 64<<20, "start a new log file once one reaches this many bytes (0 = only daily)")
+	keepAlive := flag.Duration("keepalive", 15*time.Second, "TCP keepalive period for spotting dead peers (negative = off)")
+	flag.Parse()
+
+	if *hash != "" {
+		if err := hashSecret(*hash); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	overflow, err := parseOverflow(*overflowFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *queueSize < 1 {
+		log.Fatal("-queue must be at least 1")
+	}
+	if *replay < 0 {
+		log.Fatal("-replay must not be negative")
+	}
+	if *idle > 0 && (*warning < 0 || *warning >= *idle) {
+		log.Fatal("-idle-warning must be shorter than -idle")
+	}
+
+	// Keepalive probes find peers that vanished without closing the
+	// connection, which would otherwise only go once they time out as idle
+	lc := net.ListenConfig{KeepAlive: *keepAlive}
+	listener, err := lc.Listen(context.Background(), "tcp", *addr)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var httpListener net.Listener
+	if *httpAddr != "" {
+		if httpListener, err = lc.Listen(context.Background(), "tcp", *httpAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("-tls-cert and -tls-key go together")
+	}
+	if *tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		listener = tls.NewListener(listener, tc)
+		if httpListener != nil {
+			httpListener = tls.NewListener(httpListener, tc)
+		}
+	}
+
+	var auth credentialStore
+	if *users != "" {
+		u, err := loadUsersFile(*users)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth = u
+	} else if *guests {
+		log.Fatal("-guests needs -users")
+	}
+
+	var msgs *msgLog
+	if *logDir != "" {
+		if msgs, err = openMsgLog(*logDir, *logMaxSize); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s := newServer(config{
+		queueSize:   *queueSize,
+		overflow:    overflow,
+		idleTimeout: *idle,
+		idleWarning: *warning,
+		history:     historyConfig{size: max(*historySize, 0), maxAge: *historyAge, replay: *replay},
+		auth:        auth,
+		guests:      *guests,
+		log:         msgs,
+	})
+	go s.broadcaster() // run broadcaster concurently
+	if httpListener != nil {
+		go s.serveHTTP(httpListener)
+	}
+	log.Fatal(s.serve(listener))
+}
 
-	go broadcaster() // run broadcaster concurently
+// serve accepts TCP connections on l until l is closed. Other accept
+// errors, such as running out of file descriptors, are logged and retried
+// after a pause that grows while they last.
+func (s *server) serve(l net.Listener) error {
+	var backoff time.Duration
 	for {
-		conn, err := listener.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			log.Print(err)
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+			log.Printf("accept: %v; retrying in %v", err, backoff)
+			time.Sleep(backoff)
 			continue
 		}
-		go handleConn(conn)
+		backoff = 0
+		go s.handleConn(conn)
 	}
 }
 
-// manages the communications
-func broadcaster() {
-	clients := make(map[client]bool) // all connected clients
+// manages the communications. It never waits on a client: sends go
+// through each client's bounded queue.
+func (s *server) broadcaster() {
+	h := newHub(s.history)
+	h.auth = s.auth
+	h.log = s.log
+	h.notices = s.notices
 	for {
 		select {
-		case msg := <-messages:
-			// Broadcast incoming message to all
-			// clients' outgoing message channels.
-			for cli := range clients {
-				cli.out_channel <- msg
-			}
-
-		case cli := <-entering:
+		case msg := <-s.messages:
+			h.say(msg.from, msg.text)
 
-			// Prints currently in chat if there are any users
-			if len(clients) != 0 {
-				cli.out_channel <- " " // formatting line
-				cli.out_channel <- "Currently in chat: "
-				for user := range clients {
-					cli.out_channel <- " 	" + user.username
-				}
+		case msg := <-s.notices:
+			if h.clients[msg.from.id] == msg.from {
+				msg.from.send(msg.text)
 			}
-			cli.out_channel <- " " // formatting line
 
-			clients[cli] = true
+		case e := <-s.entering:
+			e.result <- h.enter(e.cli, e.username)
 
-		case cli := <-leaving:
-			delete(clients, cli)
-			close(cli.out_channel)
+		case cli := <-s.leaving:
+			h.leave(cli)
+			close(cli.out)
+			if cli.dropped > 0 {
+				log.Printf("%s (id %d) missed %d messages while not reading", cli.username, cli.id, cli.dropped)
+			}
 		}
 	}
 }
 
-func handleConn(conn net.Conn) {
-	ch := make(chan string) // outgoing client messages
-	go clientWriter(conn, ch)
-
-	//who := conn.RemoteAddr().String()
-	//ch <- "You are " + who
-	ch <- "Choose username: "
-
-	usernameCh := make(chan string)
-	input := bufio.NewScanner(conn)
+func (s *server) handleConn(conn net.Conn) {
+	client := newClient("", conn, s.queueSize, s.overflow)
+	written := make(chan struct{})
+	go func() {
+		clientWriter(conn, client.out)
+		close(written)
+	}()
 
+	// Lines are read on their own goroutine so that waiting for one can
+	// time out. Hanging up on the client makes the read fail, which ends
+	// the goroutine and closes lines.
+	lines := make(chan string)
 	go func() {
-		if input.Scan() {
-			usernameCh <- input.Text()
-		} else {
-			// Handle the error or disconnection scenario
-			usernameCh <- "DefaultUsername"
+		defer close(lines)
+		input := bufio.NewScanner(conn)
+		for input.Scan() {
+			lines <- input.Text()
 		}
+		// NOTE: ignoring potential errors from input.Err()
 	}()
 
-	// Wait for the username input
-	username := <-usernameCh
-	client := newClient(username, ch)
+	// Let the writer finish what is queued before closing, but don't wait
+	// on a peer that has stopped reading
+	closeConn := func() {
+		select {
+		case <-written:
+		case <-time.After(kickTimeout):
+		}
+		conn.Close()
+	}
+
+	client.send("Choose username: ")
+	for failures := 0; ; {
+		line, ok := s.readLine(client, lines)
+		username := strings.TrimSpace(line)
+		var err error
+		if ok {
+			ok, err = s.login(client, username, lines)
+		}
+		if !ok {
+			// Gone before picking a name; nobody else knows about it
+			close(client.out)
+			closeConn()
+			return
+		}
+		if err == nil {
+			err = s.enter(client, username)
+		}
+		if err == nil {
+			break
+		}
+		client.send("Sorry, " + err.Error() + ".")
+		if errors.Is(err, errWrongSecret) {
+			if failures++; failures == maxLoginFailures {
+				client.send("Too many failed logins.")
+				close(client.out)
+				closeConn()
+				return
+			}
+		}
+		client.send("Choose username: ")
+	}
 
-	messages <- client.username + " has arrived"
-	messages <- " " // formatting line
+	for {
+		line, ok := s.readLine(client, lines)
+		if !ok {
+			break
+		}
+		s.messages <- message{client, line}
+	}
 
-	entering <- *client
+	s.leaving <- client
+	closeConn()
+}
 
-	for input.Scan() {
-		messages <- client.username + ": " + input.Text()
+// readLine returns the client's next line, or false once the connection
+// is over. A client silent for the idle timeout is warned, then hung up on
+// and marked as timed out.
+func (s *server) readLine(cli *client, lines <-chan string) (string, bool) {
+	if s.idleTimeout <= 0 {
+		line, ok := <-lines
+		return line, ok
 	}
-	// NOTE: ignoring potential errors from input.Err()
 
-	leaving <- *client
-	messages <- client.username + " has left"
+	warned := s.idleWarning <= 0
+	timer := time.NewTimer(s.idleTimeout - s.idleWarning)
+	if warned {
+		timer.Reset(s.idleTimeout)
+	}
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			return line, ok
+		case <-timer.C:
+		}
+		if !warned {
+			warned = true
+			s.tell(cli, fmt.Sprintf("You have been idle for %v and will be disconnected in %v unless you say something.",
+				s.idleTimeout-s.idleWarning, s.idleWarning))
+			timer.Reset(s.idleWarning)
+			continue
+		}
+		cli.timedOut = true
+		s.tell(cli, "Disconnected for being idle.")
+		cli.hangUp()
+		for range lines {
+			// Wait for the reader to notice
+		}
+		return "", false
+	}
+}
 
-	//maybe some clean up
+// tell sends text to the client from outside the hub. Until the client
+// has entered, handleConn is the only one sending to it and can do so
+// directly; after that it has to go through the broadcaster.
+func (s *server) tell(cli *client, text string) {
+	if cli.id == 0 {
+		cli.send(text)
+		return
+	}
+	s.notices <- message{cli, text}
+}
 
-	conn.Close()
+// enter asks the broadcaster to let cli in as username
+func (s *server) enter(cli *client, username string) error {
+	result := make(chan error, 1)
+	s.entering <- entry{cli, username, result}
+	return <-result
 }
 
 func clientWriter(conn net.Conn, ch <-chan string) {
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testClient is the far end of a client connection
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// join connects a client to s over an in-memory pipe and picks a username.
+// The pipe has no buffer, so a client that stops reading stalls the
+// server's writes to it straight away, like a peer with a full TCP window.
+func join(t *testing.T, s *server, username string) *testClient {
+	t.Helper()
+	conn, peer := pipe(t)
+	go s.handleConn(conn)
+
+	c := &testClient{conn: peer, r: bufio.NewReader(peer)}
+	c.expect(t, "Choose username")
+	fmt.Fprintln(peer, username)
+	// Nothing reaches a client before it has entered, so the first line of
+	// the welcome means it is in the chat
+	c.expect(t, " \n")
+	return c
+}
+
+// pipe returns the two ends of an in-memory connection, closing the test's
+// end when the test is over
+func pipe(t *testing.T) (server, peer net.Conn) {
+	server, peer = net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+	return server, peer
+}
+
+// flood sends n numbered messages from c in the background, so that c
+// can read its own messages while they go out
+func (c *testClient) flood(n int) {
+	go func() {
+		for i := range n {
+			fmt.Fprintf(c.conn, "m%d\n", i)
+		}
+	}()
+}
+
+// expect reads lines until one contains want, failing after a few seconds.
+// want may include the newline to match the end of a line.
+func (c *testClient) expect(t *testing.T, want string) {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", want, err)
+		}
+		if strings.Contains(line, want) {
+			return
+		}
+	}
+}
+
+// drain returns everything the client is sent until the server goes quiet
+func (c *testClient) drain() string {
+	var b strings.Builder
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		line, err := c.r.ReadString('\n')
+		b.WriteString(line)
+		if err != nil {
+			return b.String()
+		}
+	}
+}
+
+func startServer(queueSize int, overflow overflowPolicy) *server {
+	return startServerConfig(config{queueSize: queueSize, overflow: overflow})
+}
+
+func startServerConfig(cfg config) *server {
+	s := newServer(cfg)
+	go s.broadcaster()
+	return s
+}
+
+// floodPastSlowClient has a client that stops reading join, then sends
+// more messages than its queue holds. The other clients must keep getting
+// messages throughout.
+func floodPastSlowClient(t *testing.T, overflow overflowPolicy) (slow *testClient) {
+	s := startServer(8, overflow)
+	fast := join(t, s, "fast")
+	slow = join(t, s, "slow")
+
+	fast.flood(50)
+	fast.expect(t, "fast: m49")
+	return slow
+}
+
+func TestSlowClientDropNewest(t *testing.T) {
+	got := floodPastSlowClient(t, dropNewest).drain()
+	if !strings.Contains(got, "fast: m0\n") || strings.Contains(got, "fast: m49") {
+		t.Errorf("slow client got %q; want the first messages only", got)
+	}
+}
+
+func TestSlowClientDropOldest(t *testing.T) {
+	got := floodPastSlowClient(t, dropOldest).drain()
+	if strings.Contains(got, "fast: m0\n") || !strings.Contains(got, "fast: m49") {
+		t.Errorf("slow client got %q; want the latest messages only", got)
+	}
+}
+
+func TestSlowClientDisconnect(t *testing.T) {
+	s := startServer(8, disconnect)
+	fast := join(t, s, "fast")
+	join(t, s, "slow")
+
+	fast.flood(50)
+	fast.expect(t, "fast: m49")
+	// The slow client never reads, so the notice times out and it is cut off
+	fast.expect(t, "slow was disconnected for not keeping up")
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s := startServerConfig(config{
+		queueSize:   16,
+		idleTimeout: 300 * time.Millisecond,
+		idleWarning: 150 * time.Millisecond,
+	})
+	chatty := join(t, s, "chatty")
+	quiet := join(t, s, "quiet")
+
+	// chatty keeps talking past the timeout and stays
+	go func() {
+		for range 20 {
+			fmt.Fprintln(chatty.conn, "still here")
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	quiet.expect(t, "will be disconnected")
+	quiet.expect(t, "Disconnected for being idle")
+	chatty.expect(t, "quiet was disconnected for being idle")
+	chatty.expect(t, "chatty: still here")
+}
+
+func TestIdleBeforeUsername(t *testing.T) {
+	s := startServerConfig(config{queueSize: 16, idleTimeout: 100 * time.Millisecond})
+	conn, peer := pipe(t)
+	go s.handleConn(conn)
+	c := &testClient{conn: peer, r: bufio.NewReader(peer)}
+	c.expect(t, "Choose username")
+	c.expect(t, "Disconnected for being idle")
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.r.ReadString('\n'); err == nil || errorIsTimeout(err) {
+		t.Errorf("connection still open: %v", err)
+	}
+}
+
+// flakyListener fails its first Accepts the way a process out of file
+// descriptors does, then accepts real connections
+type flakyListener struct {
+	net.Listener
+	failures int // only touched by serve's goroutine
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeRetriesAcceptErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := startServer(16, dropNewest)
+	served := make(chan error, 1)
+	go func() { served <- s.serve(&flakyListener{Listener: l, failures: 3}) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &testClient{conn: conn, r: bufio.NewReader(conn)}
+	c.expect(t, "Choose username")
+
+	l.Close()
+	select {
+	case err := <-served:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("serve = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("serve kept going after the listener was closed")
+	}
+}
@@ -0,0 +1,360 @@
+// Package chatlog stores chat room messages in append-only files and
+// searches them.
+//
+// Each room has a directory under the log directory, named after the room
+// without its #, holding one file per day (UTC) such as 2024-05-01.log.
+// A day that outgrows the size limit continues in 2024-05-01.1.log, then
+// .2 and so on. Every line is one Record as JSON.
+package chatlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one message said in a room
+type Record struct {
+	ID     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	Room   string    `json:"room"`
+	User   string    `json:"user"`
+	Text   string    `json:"text"`
+	Action bool      `json:"action,omitempty"` // said with /me
+}
+
+// String formats r the way the chat shows it, with its date and time
+func (r Record) String() string {
+	line := r.Room + " " + r.User + ": " + r.Text
+	if r.Action {
+		line = r.Room + " * " + r.User + " " + r.Text
+	}
+	return "[" + r.Time.Local().Format("2006-01-02 15:04:05") + "] " + line
+}
+
+// maxOpen is how many rooms' files a Writer keeps open. Rooms come and go
+// at users' whim, so the least recently written is closed to make room,
+// and reopened if it is written again.
+const maxOpen = 32
+
+// Writer appends records to the logs in a directory. It is not safe for
+// concurrent use.
+type Writer struct {
+	dir     string
+	maxSize int64 // rotate a room's file once it reaches this size, 0 = daily only
+	files   map[string]*logFile
+	writes  uint64 // counts writes, to find the least recently used file
+}
+
+// logFile is the file a room is currently being logged to
+type logFile struct {
+	f        *os.File
+	w        *bufio.Writer
+	day      string // date the file is for
+	part     int    // 0 for the day's first file, then 1, 2, ...
+	size     int64
+	lastUsed uint64 // value of Writer.writes when last written
+}
+
+// NewWriter returns a Writer for the logs in dir, which is created if
+// needed. Files are rotated every day, and also when they reach maxSize
+// bytes unless maxSize is 0.
+func NewWriter(dir string, maxSize int64) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{dir: dir, maxSize: maxSize, files: make(map[string]*logFile)}, nil
+}
+
+// Write appends r to its room's log. The write is buffered; call Flush to
+// push it to the file.
+func (w *Writer) Write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	lf, err := w.file(r.Room, r.Time.UTC().Format(time.DateOnly), int64(len(line)))
+	if err != nil {
+		return err
+	}
+	w.writes++
+	lf.lastUsed = w.writes
+	n, err := lf.w.Write(line)
+	lf.size += int64(n)
+	return err
+}
+
+// file returns the file the next n bytes for room go to, rotating or
+// opening one as needed
+func (w *Writer) file(room, day string, n int64) (*logFile, error) {
+	lf := w.files[room]
+	if lf != nil && lf.day == day && (w.maxSize <= 0 || lf.size == 0 || lf.size+n <= w.maxSize) {
+		return lf, nil
+	}
+
+	roomDir := filepath.Join(w.dir, roomDirName(room))
+	if err := os.MkdirAll(roomDir, 0o755); err != nil {
+		return nil, err
+	}
+	var part int
+	if lf != nil && lf.day == day {
+		part = lf.part + 1
+	} else {
+		// Carry on with the latest of the day's files, which is there if
+		// the server was restarted
+		part = lastPart(roomDir, day)
+	}
+	if lf != nil {
+		delete(w.files, room)
+		if err := lf.close(); err != nil {
+			return nil, err
+		}
+	} else if len(w.files) >= maxOpen {
+		if err := w.closeLeastUsed(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		f, err := os.OpenFile(filepath.Join(roomDir, fileName(day, part)), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if w.maxSize > 0 && st.Size() > 0 && st.Size()+n > w.maxSize {
+			f.Close()
+			part++
+			continue
+		}
+		lf = &logFile{f: f, w: bufio.NewWriter(f), day: day, part: part, size: st.Size()}
+		if err := lf.finishLine(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		w.files[room] = lf
+		return lf, nil
+	}
+}
+
+// closeLeastUsed closes the file written longest ago
+func (w *Writer) closeLeastUsed() error {
+	var oldest string
+	for room, lf := range w.files {
+		if oldest == "" || lf.lastUsed < w.files[oldest].lastUsed {
+			oldest = room
+		}
+	}
+	lf := w.files[oldest]
+	delete(w.files, oldest)
+	return lf.close()
+}
+
+// Flush writes any buffered records to their files
+func (w *Writer) Flush() error {
+	var errs []error
+	for _, lf := range w.files {
+		errs = append(errs, lf.w.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close flushes and closes every file
+func (w *Writer) Close() error {
+	var errs []error
+	for room, lf := range w.files {
+		errs = append(errs, lf.close())
+		delete(w.files, room)
+	}
+	return errors.Join(errs...)
+}
+
+// finishLine ends a line left half written by a crash, so that the next
+// record starts on a line of its own
+func (lf *logFile) finishLine() error {
+	if lf.size == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := lf.f.ReadAt(last, lf.size-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		lf.w.WriteByte('\n')
+		lf.size++
+	}
+	return nil
+}
+
+func (lf *logFile) close() error {
+	return errors.Join(lf.w.Flush(), lf.f.Close())
+}
+
+func roomDirName(room string) string {
+	return strings.TrimPrefix(room, "#")
+}
+
+func fileName(day string, part int) string {
+	if part == 0 {
+		return day + ".log"
+	}
+	return day + "." + strconv.Itoa(part) + ".log"
+}
+
+// parseFileName is the reverse of fileName
+func parseFileName(name string) (day string, part int, ok bool) {
+	base, found := strings.CutSuffix(name, ".log")
+	if !found {
+		return "", 0, false
+	}
+	day, partStr, hasPart := strings.Cut(base, ".")
+	if _, err := time.Parse(time.DateOnly, day); err != nil {
+		return "", 0, false
+	}
+	if hasPart {
+		p, err := strconv.Atoi(partStr)
+		if err != nil || p < 1 {
+			return "", 0, false
+		}
+		part = p
+	}
+	return day, part, true
+}
+
+// lastPart returns the highest part number among day's files in dir
+func lastPart(dir, day string) int {
+	entries, _ := os.ReadDir(dir)
+	last := 0
+	for _, e := range entries {
+		if d, part, ok := parseFileName(e.Name()); ok && d == day {
+			last = max(last, part)
+		}
+	}
+	return last
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Room  string    // only this room
+	User  string    // only this user, ignoring case
+	Since time.Time // only records at or after this time
+	Until time.Time // only records before this time
+	Terms []string  // only records whose text contains all of these, ignoring case
+}
+
+// Match reports whether r passes the filter
+func (f Filter) Match(r Record) bool {
+	switch {
+	case f.Room != "" && r.Room != f.Room:
+		return false
+	case f.User != "" && !strings.EqualFold(r.User, f.User):
+		return false
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !r.Time.Before(f.Until):
+		return false
+	}
+	text := strings.ToLower(r.Text)
+	for _, term := range f.Terms {
+		if !strings.Contains(text, strings.ToLower(term)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search calls fn for every record in dir that matches f, room by room and
+// in time order within each room, until fn returns false. Files wholly
+// outside f's time range are skipped without being read. A line that
+// cannot be parsed, such as one cut short by a crash, is skipped.
+func Search(dir string, f Filter, fn func(Record) bool) error {
+	rooms, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, room := range rooms {
+		if !room.IsDir() || f.Room != "" && roomDirName(f.Room) != room.Name() {
+			continue
+		}
+		more, err := searchRoom(filepath.Join(dir, room.Name()), f, fn)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+func searchRoom(dir string, f Filter, fn func(Record) bool) (more bool, err error) {
+	type logName struct {
+		name string
+		day  string
+		part int
+	}
+	var files []logName
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if day, part, ok := parseFileName(e.Name()); ok {
+			files = append(files, logName{e.Name(), day, part})
+		}
+	}
+	slices.SortFunc(files, func(a, b logName) int {
+		if c := strings.Compare(a.day, b.day); c != 0 {
+			return c
+		}
+		return a.part - b.part
+	})
+
+	for _, lf := range files {
+		day, _ := time.Parse(time.DateOnly, lf.day)
+		if !f.Since.IsZero() && !day.AddDate(0, 0, 1).After(f.Since) ||
+			!f.Until.IsZero() && !day.Before(f.Until) {
+			continue
+		}
+		if more, err := searchFile(filepath.Join(dir, lf.name), f, fn); err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func searchFile(path string, f Filter, fn func(Record) bool) (more bool, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil // rotated away since the directory was read
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var r Record
+		if json.Unmarshal(sc.Bytes(), &r) != nil {
+			continue
+		}
+		if f.Match(r) && !fn(r) {
+			return false, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,207 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var day1 = time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+func writeAll(t *testing.T, dir string, maxSize int64, recs ...Record) {
+	t.Helper()
+	w, err := NewWriter(dir, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func ids(t *testing.T, dir string, f Filter) []uint64 {
+	t.Helper()
+	var got []uint64
+	err := Search(dir, f, func(r Record) bool {
+		got = append(got, r.ID)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestRotateDaily(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, dir, 0,
+		Record{ID: 1, Time: day1, Room: "#go", User: "alice", Text: "morning"},
+		Record{ID: 2, Time: day1.Add(20 * time.Hour), Room: "#go", User: "bob", Text: "night"},
+		Record{ID: 3, Time: day1.Add(time.Hour), Room: "#lobby", User: "bob", Text: "hi"},
+	)
+	if got, want := files(t, filepath.Join(dir, "go")), []string{"2024-05-01.log", "2024-05-02.log"}; !slices.Equal(got, want) {
+		t.Errorf("#go files = %v, want %v", got, want)
+	}
+	if got, want := files(t, filepath.Join(dir, "lobby")), []string{"2024-05-01.log"}; !slices.Equal(got, want) {
+		t.Errorf("#lobby files = %v, want %v", got, want)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	var recs []Record
+	for i := range 5 {
+		recs = append(recs, Record{ID: uint64(i + 1), Time: day1.Add(time.Duration(i) * time.Minute), Room: "#go", User: "alice", Text: "hello"})
+	}
+	// Each record is about 90 bytes, so two fit in a file
+	writeAll(t, dir, 200, recs[:3]...)
+	// A restart carries on in the last file rather than the first
+	writeAll(t, dir, 200, recs[3:]...)
+
+	want := []string{"2024-05-01.1.log", "2024-05-01.2.log", "2024-05-01.log"}
+	if got := files(t, filepath.Join(dir, "go")); !slices.Equal(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if got := ids(t, dir, Filter{}); !slices.Equal(got, []uint64{1, 2, 3, 4, 5}) {
+		t.Errorf("search found %v, want every record in order", got)
+	}
+}
+
+// openFiles counts the process's open file descriptors
+func openFiles(t *testing.T) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("cannot count open files here:", err)
+	}
+	return len(fds)
+}
+
+func TestOpenFilesBounded(t *testing.T) {
+	dir := t.TempDir()
+	before := openFiles(t)
+	w, err := NewWriter(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every room is written twice, the second time after it has been
+	// closed to make way for the others
+	for round := range 2 {
+		for i := range 500 {
+			r := Record{ID: uint64(round*500 + i + 1), Time: day1, Room: fmt.Sprintf("#r%d", i), User: "alice", Text: "hi"}
+			if err := w.Write(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n := openFiles(t) - before; n > maxOpen {
+		t.Errorf("%d files open for 500 rooms, want at most %d", n, maxOpen)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := files(t, filepath.Join(dir, "r7")); !slices.Equal(got, []string{"2024-05-01.log"}) {
+		t.Errorf("reopened room has files %v, want one", got)
+	}
+	if got := ids(t, dir, Filter{Room: "#r7"}); !slices.Equal(got, []uint64{8, 508}) {
+		t.Errorf("reopened room has records %v, want 8 and 508", got)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, dir, 0,
+		Record{ID: 1, Time: day1, Room: "#go", User: "alice", Text: "Deploy failed"},
+		Record{ID: 2, Time: day1.Add(time.Hour), Room: "#go", User: "bob", Text: "deploy worked"},
+		Record{ID: 3, Time: day1.Add(48 * time.Hour), Room: "#go", User: "Alice", Text: "deploy failed again"},
+		Record{ID: 4, Time: day1.Add(time.Hour), Room: "#ops", User: "alice", Text: "deploy failed", Action: true},
+	)
+	tests := []struct {
+		name string
+		f    Filter
+		want []uint64
+	}{
+		{"all", Filter{}, []uint64{1, 2, 3, 4}},
+		{"room", Filter{Room: "#ops"}, []uint64{4}},
+		{"user ignores case", Filter{User: "ALICE"}, []uint64{1, 3, 4}},
+		{"terms all match, ignoring case", Filter{Terms: []string{"FAILED", "deploy"}}, []uint64{1, 3, 4}},
+		{"since", Filter{Since: day1.Add(time.Hour)}, []uint64{2, 3, 4}},
+		{"until is exclusive", Filter{Until: day1.Add(time.Hour)}, []uint64{1}},
+		{"range skipping a day", Filter{Since: day1.Add(24 * time.Hour), Until: day1.Add(72 * time.Hour)}, []uint64{3}},
+	}
+	for _, tt := range tests {
+		if got := ids(t, dir, tt.f); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: found %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchSkipsDamage(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, dir, 0, Record{ID: 1, Time: day1, Room: "#go", User: "alice", Text: "first"})
+
+	// A crash mid-write leaves half a line; later records follow it
+	path := filepath.Join(dir, "go", "2024-05-01.log")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":2,"time":"2024-05-01T09:0`)
+	f.Close()
+	writeAll(t, dir, 0, Record{ID: 3, Time: day1.Add(time.Minute), Room: "#go", User: "alice", Text: "third"})
+	os.WriteFile(filepath.Join(dir, "go", "notes.txt"), []byte("not a log"), 0o644)
+
+	if got := ids(t, dir, Filter{}); !slices.Equal(got, []uint64{1, 3}) {
+		t.Errorf("found %v, want 1 and 3 around the damage", got)
+	}
+}
+
+func TestSearchStops(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, dir, 0,
+		Record{ID: 1, Time: day1, Room: "#a", User: "alice", Text: "x"},
+		Record{ID: 2, Time: day1, Room: "#b", User: "alice", Text: "x"},
+	)
+	n := 0
+	Search(dir, Filter{}, func(Record) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("fn called %d times after returning false, want 1", n)
+	}
+}
+
+func TestRecordString(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	r := Record{Time: at, Room: "#go", User: "alice", Text: "hi"}
+	if got, want := r.String(), "[2024-05-01 09:30:00] #go alice: hi"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	r.Action, r.Text = true, "waves"
+	if got, want := r.String(), "[2024-05-01 09:30:00] #go * alice waves"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// overflowPolicy says what to do with a message for a client whose
+// outbound queue is already full
+type overflowPolicy int
+
+const (
+	dropOldest overflowPolicy = iota // make room by discarding the oldest queued message
+	dropNewest                       // discard the new message
+	disconnect                       // tell the client it is too slow and hang up
+)
+
+var overflowNames = map[string]overflowPolicy{
+	"drop-oldest": dropOldest,
+	"drop-newest": dropNewest,
+	"disconnect":  disconnect,
+}
+
+func parseOverflow(s string) (overflowPolicy, error) {
+	p, ok := overflowNames[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown overflow policy %q: want drop-oldest, drop-newest or disconnect", s)
+	}
+	return p, nil
+}
+
+func (p overflowPolicy) String() string {
+	for name, q := range overflowNames {
+		if q == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("overflowPolicy(%d)", int(p))
+}
+
+// kickTimeout bounds how long we try to deliver the notice to a client
+// being disconnected for not reading
+const kickTimeout = time.Second
+
+type client struct {
+	id       uint64      // assigned by the hub on entry; identifies the client
+	out      chan string // outgoing messages, bounded; clientWriter drains it
+	username string      // stores client username, unique ignoring case
+	account  string      // registered name the client logged in as, if any
+	conn     net.Conn
+	overflow overflowPolicy
+
+	// Only the goroutine sending to out touches these: handleConn until the
+	// client has entered, the broadcaster after that
+	dropped int  // messages lost to a full queue
+	kicked  bool // disconnected for being too slow; send does nothing
+
+	// Set by handleConn before the client leaves
+	timedOut bool // disconnected for being idle
+
+	// Owned by the broadcaster's hub
+	rooms   map[*room]bool // rooms the client is in
+	current *room          // where its messages go, nil if in no room
+}
+
+// Constructor function for client
+func newClient(username string, conn net.Conn, queueSize int, overflow overflowPolicy) *client { // Returns a pointer to client struct
+	return &client{
+		out:      make(chan string, queueSize),
+		username: username,
+		conn:     conn,
+		overflow: overflow,
+		rooms:    make(map[*room]bool),
+	}
+}
+
+// send queues msg for the client without ever blocking, applying the
+// overflow policy if the queue is full. It must not be called from more
+// than one goroutine at a time.
+func (cli *client) send(msg string) {
+	if cli.kicked {
+		return
+	}
+	for {
+		select {
+		case cli.out <- msg:
+			return
+		default:
+		}
+
+		switch cli.overflow {
+		case dropNewest:
+			cli.dropped++
+			return
+		case disconnect:
+			cli.kicked = true
+			go cli.kick()
+			return
+		}
+		// dropOldest: the writer may have made room in the meantime, in
+		// which case there is nothing to drop and the send is retried
+		select {
+		case <-cli.out:
+			cli.dropped++
+		default:
+		}
+	}
+}
+
+// kick tells the client why it is being dropped, as far as a stalled
+// connection allows, and closes it. Its handleConn then sees the
+// connection end and the client leaves as usual.
+func (cli *client) kick() {
+	cli.conn.SetWriteDeadline(time.Now().Add(kickTimeout))
+	fmt.Fprintln(cli.conn, "Disconnected: you are not reading messages fast enough")
+	cli.conn.Close()
+}
+
+// hangUp ends the client's session: its handleConn stops reading and the
+// client leaves as usual, after what is already queued has been written
+func (cli *client) hangUp() {
+	cli.conn.SetReadDeadline(time.Now())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// queued empties cli's queue without a writer attached
+func queued(cli *client) []string {
+	var msgs []string
+	for {
+		select {
+		case msg := <-cli.out:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestSendOverflow(t *testing.T) {
+	tests := []struct {
+		overflow overflowPolicy
+		want     []string
+	}{
+		{dropOldest, []string{"b", "c"}},
+		{dropNewest, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		cli := newClient("user", nil, 2, tt.overflow)
+		for _, msg := range []string{"a", "b", "c"} {
+			cli.send(msg)
+		}
+		if got := queued(cli); !slices.Equal(got, tt.want) {
+			t.Errorf("%v: queue = %v, want %v", tt.overflow, got, tt.want)
+		}
+		if cli.dropped != 1 {
+			t.Errorf("%v: dropped = %d, want 1", tt.overflow, cli.dropped)
+		}
+	}
+}
+
+func TestSendDisconnect(t *testing.T) {
+	server, peer := net.Pipe()
+	defer peer.Close()
+	cli := newClient("user", server, 1, disconnect)
+	cli.send("a")
+	cli.send("b")
+	if !cli.kicked {
+		t.Fatal("client was not kicked")
+	}
+	cli.send("c") // ignored once kicked
+
+	line, err := bufio.NewReader(peer).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "Disconnected") {
+		t.Errorf("peer read %q, %v; want the disconnect notice", line, err)
+	}
+	if got := queued(cli); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("queue = %v, want [a]", got)
+	}
+}
+
+func TestParseOverflow(t *testing.T) {
+	for _, p := range []overflowPolicy{dropOldest, dropNewest, disconnect} {
+		got, err := parseOverflow(p.String())
+		if err != nil || got != p {
+			t.Errorf("parseOverflow(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := parseOverflow("block"); err == nil {
+		t.Error("parseOverflow(block) did not fail")
+	}
+}
@@ -0,0 +1,101 @@
+// Chatlog searches the message logs the chat server writes with -log-dir:
+//
+//	chatlog -dir logs -room '#go' -since 2024-05-01 -until 2024-05-08
+//	chatlog -dir logs -user alice -since 2h deploy failed
+//
+// Messages must contain every term given after the flags, ignoring case.
+// -since and -until take an RFC 3339 time, a local date or date and time
+// such as 2024-05-01 or "2024-05-01 15:04", or a duration, meaning that
+// long ago. Matches are printed oldest first, across all rooms searched.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"chat/chatlog"
+)
+
+func main() {
+	dir := flag.String("dir", "", "log directory the server was started with (required)")
+	room := flag.String("room", "", "only this room, such as #go")
+	user := flag.String("user", "", "only messages from this user")
+	since := flag.String("since", "", "only messages at or after this time")
+	until := flag.String("until", "", "only messages before this time")
+	asJSON := flag.Bool("json", false, "print the matching records as JSON lines")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chatlog -dir logs [flags] [terms...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("chatlog: ")
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	f := chatlog.Filter{User: *user, Terms: flag.Args()}
+	if *room != "" {
+		f.Room = "#" + strings.TrimPrefix(strings.ToLower(*room), "#")
+	}
+	var err error
+	now := time.Now()
+	if f.Since, err = parseTime(*since, now); err != nil {
+		log.Fatalf("-since: %v", err)
+	}
+	if f.Until, err = parseTime(*until, now); err != nil {
+		log.Fatalf("-until: %v", err)
+	}
+
+	// Rooms are searched one after another, so the matches are gathered
+	// and sorted to print them as a single timeline
+	var recs []chatlog.Record
+	err = chatlog.Search(*dir, f, func(r chatlog.Record) bool {
+		recs = append(recs, r)
+		return true
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	slices.SortStableFunc(recs, func(a, b chatlog.Record) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range recs {
+		if *asJSON {
+			err = enc.Encode(r)
+		} else {
+			_, err = fmt.Println(r)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// parseTime reads a -since or -until value; empty means no limit
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateOnly, "2006-01-02 15:04", time.DateTime} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot read %q as a time or duration", s)
+}
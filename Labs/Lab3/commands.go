@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chat/chatlog"
+)
+
+// command is something a client can run by typing /name. Commands run on
+// the broadcaster goroutine, so they may use the hub freely but must not
+// block. Whatever they report goes to the caller only.
+type command interface {
+	usage() string // how to call it, e.g. "/join #room"
+	help() string  // what it does, in a few words
+
+	// run carries out the command. args is everything typed after the
+	// command name, with surrounding spaces removed. Returning errUsage
+	// shows the caller the usage text; any other error is shown as is.
+	run(h *hub, cli *client, args string) error
+}
+
+// errUsage is returned by a command called with the wrong arguments
+var errUsage = errors.New("wrong arguments")
+
+// commands holds every command by name, including the slash
+var commands = make(map[string]command)
+
+// register adds a command. It panics if the name is taken, so that two
+// commands cannot silently fight over a name.
+func register(name string, c command) {
+	if _, dup := commands[name]; dup {
+		panic("chat: command " + name + " registered twice")
+	}
+	commands[name] = c
+}
+
+// dispatch runs the command typed on line
+func (h *hub) dispatch(cli *client, line string) {
+	name, args, _ := strings.Cut(line, " ")
+	c, ok := commands[strings.ToLower(name)]
+	if !ok {
+		cli.send("Unknown command " + name + "; try /help")
+		return
+	}
+	switch err := c.run(h, cli, strings.TrimSpace(args)); {
+	case errors.Is(err, errUsage):
+		cli.send("Usage: " + c.usage())
+	case err != nil:
+		cli.send("Error: " + err.Error())
+	}
+}
+
+// simpleCommand implements command with a function, for the built-ins
+type simpleCommand struct {
+	use, desc string
+	fn        func(h *hub, cli *client, args string) error
+}
+
+func (c simpleCommand) usage() string { return c.use }
+func (c simpleCommand) help() string  { return c.desc }
+
+func (c simpleCommand) run(h *hub, cli *client, args string) error {
+	return c.fn(h, cli, args)
+}
+
+func init() {
+	register("/help", simpleCommand{"/help [command]", "list commands, or explain one", cmdHelp})
+	register("/join", simpleCommand{"/join #room", "join a room, creating it if needed, and talk there", cmdJoin})
+	register("/leave", simpleCommand{"/leave [#room]", "leave a room, by default the current one", cmdLeave})
+	register("/rooms", simpleCommand{"/rooms", "list rooms and how many are in each", cmdRooms})
+	register("/who", simpleCommand{"/who [#room]", "list who is in a room, by default the current one", cmdWho})
+	register("/msg", simpleCommand{"/msg <user> <text>", "send a private message", cmdMsg})
+	register("/me", simpleCommand{"/me <action>", "describe what you are doing", cmdMe})
+	register("/nick", simpleCommand{"/nick <name>", "change your username", cmdNick})
+	register("/history", simpleCommand{"/history [N] [#room]", "show the last N messages of a room, by default the current one", cmdHistory})
+	register("/search", simpleCommand{"/search [#room] <terms>", "find logged messages containing all the terms", cmdSearch})
+	register("/quit", simpleCommand{"/quit", "leave the chat", cmdQuit})
+}
+
+func cmdHelp(h *hub, cli *client, args string) error {
+	if args != "" {
+		name := strings.ToLower(args)
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		c, ok := commands[name]
+		if !ok {
+			return fmt.Errorf("no command %s", name)
+		}
+		cli.send(c.usage() + ": " + c.help())
+		return nil
+	}
+	cli.send("Commands:")
+	for _, name := range sortedKeys(commands) {
+		c := commands[name]
+		cli.send(" 	" + c.usage() + ": " + c.help())
+	}
+	return nil
+}
+
+func cmdJoin(h *hub, cli *client, args string) error {
+	if args == "" || strings.ContainsAny(args, " \t") {
+		return errUsage
+	}
+	room := strings.ToLower(args)
+	if !validRoom(room) {
+		return fmt.Errorf("invalid room name %q: use # and up to %d letters, digits, - or _", args, maxRoomName-1)
+	}
+	h.join(cli, room, cli.username+" has joined "+room)
+	return nil
+}
+
+// roomArg returns the room named in args, or cli's current room
+func roomArg(h *hub, cli *client, args string) (*room, error) {
+	switch {
+	case strings.ContainsAny(args, " \t"):
+		return nil, errUsage
+	case args != "":
+		r, ok := h.rooms[strings.ToLower(args)]
+		if !ok {
+			return nil, errors.New("no such room")
+		}
+		return r, nil
+	case cli.current == nil:
+		return nil, errors.New("you are not in any room")
+	}
+	return cli.current, nil
+}
+
+func cmdLeave(h *hub, cli *client, args string) error {
+	r, err := roomArg(h, cli, args)
+	if err != nil {
+		return err
+	}
+	if r.members[cli.id] == nil {
+		return errors.New("you are not in " + r.name)
+	}
+	h.part(cli, r, cli.username+" has left "+r.name)
+	cli.send("Left " + r.name)
+	if cli.current != nil {
+		cli.send("Now talking in " + cli.current.name)
+	}
+	return nil
+}
+
+func cmdRooms(h *hub, cli *client, args string) error {
+	if args != "" {
+		return errUsage
+	}
+	if len(h.rooms) == 0 {
+		cli.send("No rooms")
+		return nil
+	}
+	cli.send("Rooms:")
+	for _, name := range sortedKeys(h.rooms) {
+		cli.send(fmt.Sprintf(" 	%s (%d)", name, len(h.rooms[name].members)))
+	}
+	return nil
+}
+
+func cmdWho(h *hub, cli *client, args string) error {
+	r, err := roomArg(h, cli, args)
+	if err != nil {
+		return err
+	}
+	cli.send("Currently in " + r.name + ": ")
+	for _, user := range r.usernames() {
+		cli.send(" 	" + user)
+	}
+	return nil
+}
+
+func cmdMsg(h *hub, cli *client, args string) error {
+	// Keep the text as typed rather than as split into fields
+	user, text, _ := strings.Cut(args, " ")
+	text = strings.TrimSpace(text)
+	if user == "" || text == "" {
+		return errUsage
+	}
+	to, ok := h.byName[nameKey(user)]
+	if !ok {
+		return errors.New(user + " is not online")
+	}
+	h.direct(cli, to, text)
+	return nil
+}
+
+func cmdMe(h *hub, cli *client, args string) error {
+	if args == "" {
+		return errUsage
+	}
+	if cli.current == nil {
+		return errors.New("you are not in any room; /join #room first")
+	}
+	h.post(cli.current, cli, args, true)
+	return nil
+}
+
+func cmdNick(h *hub, cli *client, args string) error {
+	if args == "" || strings.ContainsAny(args, " \t") {
+		return errUsage
+	}
+	return h.rename(cli, args)
+}
+
+func cmdHistory(h *hub, cli *client, args string) error {
+	n := 20
+	if first, rest, _ := strings.Cut(args, " "); first != "" && !strings.HasPrefix(first, "#") {
+		var err error
+		if n, err = strconv.Atoi(first); err != nil || n < 1 {
+			return errUsage
+		}
+		args = strings.TrimSpace(rest)
+	}
+	r, err := roomArg(h, cli, args)
+	if err != nil {
+		return err
+	}
+	if h.history.size == 0 {
+		return errors.New("history is turned off")
+	}
+	h.replay(cli, r, n)
+	if r.history.n == 0 {
+		cli.send("Nothing said in " + r.name + " lately")
+	}
+	return nil
+}
+
+func cmdSearch(h *hub, cli *client, args string) error {
+	terms := strings.Fields(args)
+	var f chatlog.Filter
+	if len(terms) > 0 && strings.HasPrefix(terms[0], "#") {
+		f.Room = strings.ToLower(terms[0])
+		terms = terms[1:]
+	}
+	if len(terms) == 0 {
+		return errUsage
+	}
+	if h.log == nil {
+		return errors.New("the chat log is turned off")
+	}
+	f.Terms = terms
+	return h.startSearch(cli, f)
+}
+
+func cmdQuit(h *hub, cli *client, args string) error {
+	cli.send("Goodbye")
+	cli.hangUp()
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoCommand is a command defined outside the built-ins
+type echoCommand struct{}
+
+func (echoCommand) usage() string { return "/echo <text>" }
+func (echoCommand) help() string  { return "repeat text back" }
+
+func (echoCommand) run(h *hub, cli *client, args string) error {
+	if args == "" {
+		return errUsage
+	}
+	cli.send("echo: " + args)
+	return nil
+}
+
+func TestRegisterCommand(t *testing.T) {
+	register("/echo", echoCommand{})
+	defer delete(commands, "/echo")
+
+	h, c := newTestHub("alice", "bob")
+	alice, bob := c[0], c[1]
+	h.say(alice, "/ECHO  hi there ")
+	if !got(alice, "echo: hi there") || got(bob, "echo") {
+		t.Error("command output should reach the caller only")
+	}
+	h.say(alice, "/echo")
+	if !got(alice, "Usage: /echo <text>") {
+		t.Error("errUsage did not show the usage")
+	}
+	h.say(alice, "/help")
+	if !got(alice, "/echo <text>: repeat text back") {
+		t.Error("/help does not list the new command")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	register("/echo", echoCommand{})
+}
+
+func TestCommandErrorsGoToCaller(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	alice, bob := c[0], c[1]
+	for _, line := range []string{"/bogus", "/join", "/who #nope", "/help bogus", "/me"} {
+		h.say(alice, line)
+		if len(queued(alice)) == 0 {
+			t.Errorf("%q: no reply", line)
+		}
+		if msgs := queued(bob); len(msgs) != 0 {
+			t.Errorf("%q: bob was sent %q", line, msgs)
+		}
+	}
+}
+
+func TestMe(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	h.say(c[0], "/me waves")
+	if !got(c[1], "#lobby * alice waves") {
+		t.Error("bob did not see the action")
+	}
+}
+
+func TestNick(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	alice, bob := c[0], c[1]
+
+	h.say(alice, "/nick bob")
+	if !got(alice, "bob is taken") || alice.username != "alice" {
+		t.Error("took a name in use")
+	}
+	h.say(alice, "/nick ally")
+	if !got(bob, "alice is now known as ally") || !got(alice, "now known as ally") {
+		t.Error("rename was not announced")
+	}
+	h.say(bob, "/msg ally hi")
+	if !got(alice, "(private) bob: hi") {
+		t.Error("/msg does not find the new name")
+	}
+	h.say(bob, "/msg alice hi")
+	if !got(bob, "alice is not online") {
+		t.Error("/msg still finds the old name")
+	}
+}
+
+func TestQuit(t *testing.T) {
+	s := startServer(8, dropNewest)
+	alice := join(t, s, "alice")
+	bob := join(t, s, "bob")
+
+	bob.conn.Write([]byte("/quit\n"))
+	bob.expect(t, "Goodbye")
+	alice.expect(t, "bob has left")
+
+	bob.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := bob.r.ReadString('\n'); err == nil || errorIsTimeout(err) {
+		t.Errorf("connection still open after /quit: %v", err)
+	}
+}
+
+func errorIsTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func TestHelpListsBuiltins(t *testing.T) {
+	h, c := newTestHub("alice")
+	h.say(c[0], "/help")
+	msgs := strings.Join(queued(c[0]), "\n")
+	for _, name := range []string{"/nick", "/who", "/me", "/quit", "/help", "/join", "/leave", "/rooms", "/msg"} {
+		if !strings.Contains(msgs, name) {
+			t.Errorf("/help does not mention %s", name)
+		}
+	}
+	h.say(c[0], "/help nick")
+	if !slices.Contains(queued(c[0]), "/nick <name>: change your username") {
+		t.Error("/help nick does not explain /nick")
+	}
+}
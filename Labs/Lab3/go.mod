@@ -0,0 +1,8 @@
+module chat
+
+go 1.23
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.17.0
+)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// historyConfig limits what each room remembers
+type historyConfig struct {
+	size   int           // messages kept per room, 0 = none
+	maxAge time.Duration // older messages are forgotten, 0 = no limit
+	replay int           // messages shown to a client joining the room
+}
+
+// record is one message in a room's history
+type record struct {
+	at   time.Time
+	text string
+}
+
+func (r record) String() string {
+	return "[" + r.at.Format("15:04:05") + "] " + r.text
+}
+
+// history is a ring buffer of a room's most recent messages
+type history struct {
+	buf    []record
+	start  int // index of the oldest record
+	n      int // records in use
+	maxAge time.Duration
+}
+
+func newHistory(cfg historyConfig) *history {
+	return &history{buf: make([]record, cfg.size), maxAge: cfg.maxAge}
+}
+
+// add records a message, overwriting the oldest once the buffer is full
+func (h *history) add(at time.Time, text string) {
+	if len(h.buf) == 0 {
+		return
+	}
+	if h.n < len(h.buf) {
+		h.buf[(h.start+h.n)%len(h.buf)] = record{at, text}
+		h.n++
+		return
+	}
+	h.buf[h.start] = record{at, text}
+	h.start = (h.start + 1) % len(h.buf)
+}
+
+// last returns up to n of the most recent messages, oldest first,
+// forgetting any that have grown too old
+func (h *history) last(n int, now time.Time) []record {
+	if h.maxAge > 0 {
+		for h.n > 0 && now.Sub(h.buf[h.start].at) > h.maxAge {
+			h.buf[h.start] = record{}
+			h.start = (h.start + 1) % len(h.buf)
+			h.n--
+		}
+	}
+	n = min(n, h.n)
+	if n <= 0 {
+		return nil
+	}
+	recs := make([]record, n)
+	for i := range recs {
+		recs[i] = h.buf[(h.start+h.n-n+i)%len(h.buf)]
+	}
+	return recs
+}
+
+// post sends a message from cli to everyone in r, adds it to r's history
+// and logs it. An action is one said with /me.
+func (h *hub) post(r *room, from *client, text string, action bool) {
+	line := r.name + " " + from.username + ": " + text
+	if action {
+		line = r.name + " * " + from.username + " " + text
+	}
+	now := h.now()
+	h.broadcast(r, line)
+	r.history.add(now, line)
+	h.logRecord(r, from, text, action, now)
+}
+
+// replay shows cli up to n of r's recent messages
+func (h *hub) replay(cli *client, r *room, n int) {
+	recs := r.history.last(n, h.now())
+	if len(recs) == 0 {
+		return
+	}
+	cli.send(fmt.Sprintf("Last %d messages in %s:", len(recs), r.name))
+	for _, rec := range recs {
+		cli.send(" 	" + rec.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func texts(recs []record) []string {
+	var s []string
+	for _, r := range recs {
+		s = append(s, r.text)
+	}
+	return s
+}
+
+func TestHistoryRing(t *testing.T) {
+	start := time.Unix(0, 0)
+	h := newHistory(historyConfig{size: 3})
+	for i := range 5 {
+		h.add(start, fmt.Sprint(i))
+	}
+	if got := texts(h.last(10, start)); !slices.Equal(got, []string{"2", "3", "4"}) {
+		t.Errorf("last(10) = %v", got)
+	}
+	if got := texts(h.last(2, start)); !slices.Equal(got, []string{"3", "4"}) {
+		t.Errorf("last(2) = %v", got)
+	}
+	if got := newHistory(historyConfig{}).last(5, start); len(got) != 0 {
+		t.Errorf("disabled history returned %v", got)
+	}
+}
+
+func TestHistoryNegativeCount(t *testing.T) {
+	start := time.Unix(0, 0)
+	h := newHistory(historyConfig{size: 3})
+	h.add(start, "hello")
+	if got := h.last(-1, start); len(got) != 0 {
+		t.Errorf("last(-1) = %v, want nothing", got)
+	}
+
+	// A negative replay count used to crash the broadcaster on every join
+	hub, c := newTestHub("alice")
+	hub.history = historyConfig{size: 3, replay: -1}
+	hub.say(c[0], "/join #go")
+	if !got(c[0], "Now talking in #go") {
+		t.Error("alice did not join #go")
+	}
+}
+
+func TestHistoryMaxAge(t *testing.T) {
+	start := time.Unix(0, 0)
+	h := newHistory(historyConfig{size: 4, maxAge: time.Minute})
+	h.add(start, "old")
+	h.add(start.Add(time.Minute), "new")
+	if got := texts(h.last(4, start.Add(90*time.Second))); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("last = %v, want [new]", got)
+	}
+	if got := h.last(4, start.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("last = %v, want nothing", got)
+	}
+}
+
+func TestReplayOnJoin(t *testing.T) {
+	h, c := newTestHub("alice")
+	now := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+	h.history = historyConfig{size: 10, replay: 2}
+	h.now = func() time.Time { return now }
+	alice := c[0]
+
+	h.say(alice, "/join #go")
+	for _, msg := range []string{"one", "two", "three"} {
+		h.say(alice, msg)
+	}
+	h.say(alice, "/me waves")
+
+	bob := newClient("", nil, 64, dropNewest)
+	h.enter(bob, "bob")
+	queued(bob)
+	h.say(bob, "/join #go")
+	msgs := queued(bob)
+	want := []string{" 	[09:30:00] #go alice: three", " 	[09:30:00] #go * alice waves"}
+	if !slices.Equal(msgs[len(msgs)-3:len(msgs)-1], want) {
+		t.Errorf("bob saw %q, want the last two messages", msgs)
+	}
+	if slices.ContainsFunc(msgs, func(m string) bool { return strings.Contains(m, "joined") }) {
+		t.Error("notices should not be replayed")
+	}
+
+	h.say(bob, "/history 3")
+	if got := queued(bob); len(got) != 4 || !strings.HasSuffix(got[1], "alice: two") {
+		t.Errorf("/history 3 = %q", got)
+	}
+	h.say(bob, "/history 10 #lobby")
+	if !got(bob, "Nothing said in #lobby") {
+		t.Error("/history of a quiet room")
+	}
+	h.say(bob, "/history x")
+	if !got(bob, "Usage: /history") {
+		t.Error("/history x did not show usage")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"chat/chatlog"
+)
+
+const (
+	logQueue      = 1024 // records waiting to be written before new ones are dropped
+	maxSearches   = 4    // searches running at once
+	searchResults = 20   // matches shown by /search
+)
+
+// msgLog writes what is said in rooms to the chat log. Writing happens on
+// a goroutine of its own, so a slow or failing disk never holds up the
+// broadcaster: when the queue is full, records are dropped and counted.
+type msgLog struct {
+	dir      string
+	w        *chatlog.Writer
+	records  chan chatlog.Record
+	dropped  atomic.Uint64
+	done     chan struct{} // closed when run has written everything
+	searches chan struct{} // one token per search running
+}
+
+// openMsgLog starts logging to dir, rotating each room's file daily and
+// when it reaches maxSize bytes
+func openMsgLog(dir string, maxSize int64) (*msgLog, error) {
+	w, err := chatlog.NewWriter(dir, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	l := &msgLog{
+		dir:      dir,
+		w:        w,
+		records:  make(chan chatlog.Record, logQueue),
+		done:     make(chan struct{}),
+		searches: make(chan struct{}, maxSearches),
+	}
+	go l.run()
+	return l, nil
+}
+
+// add queues r to be written, or drops it if the writer is behind
+func (l *msgLog) add(r chatlog.Record) {
+	select {
+	case l.records <- r:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+// run writes queued records until close, flushing whenever the queue runs
+// dry. Errors are logged when they start and when they stop, not for every
+// record, so a full disk does not flood the server log.
+func (l *msgLog) run() {
+	defer close(l.done)
+	failing := false
+	report := func(err error) {
+		switch {
+		case err != nil && !failing:
+			log.Printf("chat log: %v; messages are not being logged", err)
+		case err == nil && failing:
+			log.Print("chat log: writing again")
+		}
+		failing = err != nil
+	}
+
+	var reported uint64
+	for r := range l.records {
+		err := l.w.Write(r)
+		if len(l.records) == 0 {
+			err = errors.Join(err, l.w.Flush())
+		}
+		report(err)
+		if n := l.dropped.Load(); n != reported {
+			log.Printf("chat log: %d messages dropped so far because writing fell behind", n)
+			reported = n
+		}
+	}
+	report(l.w.Close())
+}
+
+// close writes what is queued and closes the files. Nothing may be added
+// afterwards.
+func (l *msgLog) close() {
+	close(l.records)
+	<-l.done
+}
+
+// search finds the latest records matching f, at most searchResults of
+// them, oldest first
+func (l *msgLog) search(f chatlog.Filter) ([]chatlog.Record, error) {
+	var recs []chatlog.Record
+	byTime := func(a, b chatlog.Record) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	}
+	trim := func() {
+		slices.SortFunc(recs, byTime)
+		recs = recs[max(len(recs)-searchResults, 0):]
+	}
+	err := chatlog.Search(l.dir, f, func(r chatlog.Record) bool {
+		if recs = append(recs, r); len(recs) >= 2*searchResults {
+			trim()
+		}
+		return true
+	})
+	trim()
+	return recs, err
+}
+
+// startSearch runs a search for cli off the broadcaster goroutine, sending
+// the results back through the broadcaster when they are ready
+func (h *hub) startSearch(cli *client, f chatlog.Filter) error {
+	select {
+	case h.log.searches <- struct{}{}:
+	default:
+		return errors.New("too many searches running; try again in a moment")
+	}
+	query := strings.Join(f.Terms, " ")
+	if f.Room != "" {
+		query += " in " + f.Room
+	}
+	cli.send("Searching the log for " + query + "...")
+
+	notices := h.notices
+	go func() {
+		defer func() { <-h.log.searches }()
+		recs, err := h.log.search(f)
+		var lines []string
+		switch {
+		case err != nil:
+			log.Printf("chat log: searching: %v", err)
+			lines = []string{"Error: the search failed"}
+		case len(recs) == 0:
+			lines = []string{"No messages found for " + query}
+		default:
+			lines = append(lines, fmt.Sprintf("Latest %d messages found for %s:", len(recs), query))
+			for _, r := range recs {
+				lines = append(lines, " 	"+r.String())
+			}
+		}
+		for _, line := range lines {
+			notices <- message{cli, line}
+		}
+	}()
+	return nil
+}
+
+// logRecord stamps a message said in a room with an ID and queues it for
+// the chat log, if there is one
+func (h *hub) logRecord(r *room, from *client, text string, action bool, at time.Time) {
+	h.lastMsgID++
+	if h.log == nil {
+		return
+	}
+	h.log.add(chatlog.Record{
+		ID:     h.lastMsgID,
+		Time:   at,
+		Room:   r.name,
+		User:   from.username,
+		Text:   text,
+		Action: action,
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chat/chatlog"
+)
+
+func TestPostIsLogged(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	l, err := openMsgLog(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.log = l
+	h.say(c[0], "hello")
+	h.say(c[1], "/me waves")
+	h.say(c[0], "/msg bob not for the log")
+	l.close()
+
+	var recs []chatlog.Record
+	chatlog.Search(l.dir, chatlog.Filter{}, func(r chatlog.Record) bool {
+		recs = append(recs, r)
+		return true
+	})
+	if len(recs) != 2 {
+		t.Fatalf("logged %v, want the two room messages", recs)
+	}
+	if r := recs[0]; r.Room != lobby || r.User != "alice" || r.Text != "hello" || r.Action {
+		t.Errorf("first record = %+v", r)
+	}
+	if r := recs[1]; r.User != "bob" || r.Text != "waves" || !r.Action {
+		t.Errorf("second record = %+v", r)
+	}
+	if recs[1].ID <= recs[0].ID {
+		t.Errorf("IDs %d then %d, want them increasing", recs[0].ID, recs[1].ID)
+	}
+}
+
+func TestLogDoesNotStall(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	// No writer goroutine, like a disk that has hung
+	h.log = &msgLog{records: make(chan chatlog.Record, 2)}
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			h.say(c[0], "hello")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("posting blocked on the log")
+	}
+	if n := h.log.dropped.Load(); n != 8 {
+		t.Errorf("dropped %d records, want 8", n)
+	}
+	if !got(c[1], "alice: hello") {
+		t.Error("bob did not get the messages")
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	l, err := openMsgLog(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(l.close)
+	s := startServerConfig(config{queueSize: 16, log: l})
+	alice := join(t, s, "alice")
+	bob := join(t, s, "bob")
+
+	alice.send("deploy failed")
+	alice.send("lunch?")
+	bob.expect(t, "alice: lunch?")
+
+	// Wait for the log to reach the disk
+	path := filepath.Join(l.dir, "lobby", time.Now().UTC().Format(time.DateOnly)+".log")
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if b, _ := os.ReadFile(path); strings.Contains(string(b), "lunch") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("messages never reached the log")
+		}
+	}
+
+	bob.send("/search DEPLOY")
+	bob.expect(t, "Latest 1 messages found for DEPLOY:")
+	bob.expect(t, "#lobby alice: deploy failed\n")
+
+	bob.send("/search #nowhere deploy")
+	bob.expect(t, "No messages found for deploy in #nowhere")
+
+	bob.send("/search")
+	bob.expect(t, "Usage: /search")
+}
+
+func TestSearchNeedsLog(t *testing.T) {
+	h, c := newTestHub("alice")
+	h.say(c[0], "/search anything")
+	if !got(c[0], "Error: the chat log is turned off") {
+		t.Error("searching without a log should say it is off")
+	}
+}
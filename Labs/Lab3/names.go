@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minUsername = 2
+	maxUsername = 20
+)
+
+// reservedNames cannot be picked by anyone, so that nobody can pass for the
+// server or its staff. They are matched case-insensitively.
+var reservedNames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"everyone":      true,
+	"moderator":     true,
+	"nobody":        true,
+	"root":          true,
+	"server":        true,
+	"system":        true,
+}
+
+// validUsername checks a username: a letter, then letters, digits, - or _.
+// Only ASCII is allowed so that look-alike letters from other scripts
+// cannot be used to impersonate someone.
+func validUsername(name string) error {
+	if len(name) < minUsername || len(name) > maxUsername {
+		return fmt.Errorf("usernames are %d to %d characters long", minUsername, maxUsername)
+	}
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if i == 0 && !letter {
+			return fmt.Errorf("usernames start with a letter")
+		}
+		if !letter && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+			return fmt.Errorf("usernames may only contain letters, digits, - and _")
+		}
+	}
+	if reservedNames[nameKey(name)] {
+		return fmt.Errorf("%s is reserved", name)
+	}
+	return nil
+}
+
+// nameKey is how usernames are compared: Bob and bob are the same user
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidUsername(t *testing.T) {
+	valid := []string{"al", "Alice", "bob_2", "x-y", strings.Repeat("a", maxUsername)}
+	invalid := []string{"", "a", "2bob", "_bob", "bob smith", "bób", "bob!", "Admin", "SERVER", strings.Repeat("a", maxUsername+1)}
+	for _, name := range valid {
+		if err := validUsername(name); err != nil {
+			t.Errorf("validUsername(%q) = %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if validUsername(name) == nil {
+			t.Errorf("validUsername(%q) accepted it", name)
+		}
+	}
+}
+
+func TestUniqueUsernames(t *testing.T) {
+	h, c := newTestHub("Bob")
+	cli := newClient("", nil, 16, dropNewest)
+	if err := h.enter(cli, "bob"); err == nil || !strings.Contains(err.Error(), "taken") {
+		t.Errorf("entering as bob with Bob present = %v, want taken", err)
+	}
+	if len(h.clients) != 1 {
+		t.Error("a refused client was let in")
+	}
+	if err := h.enter(cli, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if cli.id == 0 || cli.id == c[0].id {
+		t.Errorf("IDs %d and %d should be distinct and non-zero", c[0].id, cli.id)
+	}
+
+	// Changing the case of one's own name is fine
+	h.say(c[0], "/nick BOB")
+	if c[0].username != "BOB" {
+		t.Errorf("username = %q, want BOB", c[0].username)
+	}
+	h.say(cli, "/nick bOb")
+	if !got(cli, "bOb is taken") {
+		t.Error("/nick took a name differing only in case")
+	}
+	h.say(cli, "/nick root")
+	if !got(cli, "root is reserved") {
+		t.Error("/nick took a reserved name")
+	}
+
+	// Once Bob leaves the name is free
+	h.leave(c[0])
+	if err := h.enter(newClient("", nil, 16, dropNewest), "bob"); err != nil {
+		t.Errorf("name still taken after leaving: %v", err)
+	}
+}
+
+func TestUsernameReprompt(t *testing.T) {
+	s := startServer(16, dropNewest)
+	join(t, s, "bob")
+
+	conn, peer := pipe(t)
+	go s.handleConn(conn)
+	c := &testClient{conn: peer, r: bufio.NewReader(peer)}
+	c.expect(t, "Choose username")
+	for _, name := range []string{"BOB", "x", "server"} {
+		fmt.Fprintln(peer, name)
+		c.expect(t, "Sorry")
+		c.expect(t, "Choose username")
+	}
+	fmt.Fprintln(peer, "robert")
+	c.expect(t, "Now talking in #lobby")
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// lobby is the room every client is put in on arrival
+const lobby = "#lobby"
+
+const maxRoomName = 32
+
+type room struct {
+	name    string
+	members map[uint64]*client // by client ID
+	history *history           // recent messages; forgotten with the room
+}
+
+// hub is the broadcaster's view of the chat: who is connected and which
+// rooms they are in. Only the broadcaster goroutine touches it, along with
+// the rooms and current fields of each client.
+type hub struct {
+	clients map[uint64]*client // all clients in the chat, by ID
+	byName  map[string]*client // the same, by nameKey of their username
+	rooms   map[string]*room   // by name; a room exists while it has members
+	lastID  uint64             // last client ID handed out
+
+	history historyConfig
+	now     func() time.Time // stamps history; tests replace it
+
+	auth credentialStore // registered names, which only their owners may use
+
+	log       *msgLog        // where messages are logged, nil = nowhere
+	lastMsgID uint64         // last message ID handed out
+	notices   chan<- message // for sending clients search results from other goroutines
+}
+
+func newHub(history historyConfig) *hub {
+	return &hub{
+		clients: make(map[uint64]*client),
+		byName:  make(map[string]*client),
+		rooms:   make(map[string]*room),
+		history: history,
+		now:     time.Now,
+		// Starting from the clock keeps message IDs growing across restarts
+		lastMsgID: uint64(time.Now().UnixMicro()),
+	}
+}
+
+// validRoom checks a room name: # followed by letters, digits, - or _
+func validRoom(name string) bool {
+	if len(name) < 2 || len(name) > maxRoomName || name[0] != '#' {
+		return false
+	}
+	for _, r := range name[1:] {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// enter lets cli into the chat as username, giving it an ID, unless the
+// name is invalid or someone already has it
+func (h *hub) enter(cli *client, username string) error {
+	if err := h.checkName(cli, username); err != nil {
+		return err
+	}
+	h.lastID++
+	cli.id = h.lastID
+	cli.username = username
+	h.clients[cli.id] = cli
+	h.byName[nameKey(username)] = cli
+	h.join(cli, lobby, cli.username+" has arrived")
+	return nil
+}
+
+// checkName reports why cli cannot be called name, if it cannot
+func (h *hub) checkName(cli *client, name string) error {
+	if err := validUsername(name); err != nil {
+		return err
+	}
+	if other, taken := h.byName[nameKey(name)]; taken && other != cli {
+		return fmt.Errorf("%s is taken", name)
+	}
+	if h.auth != nil && h.auth.registered(name) && nameKey(name) != nameKey(cli.account) {
+		return fmt.Errorf("%s is registered to someone else", name)
+	}
+	return nil
+}
+
+// leave takes cli out of the chat, telling its rooms why it went
+func (h *hub) leave(cli *client) {
+	notice := cli.username + " has left"
+	switch {
+	case cli.timedOut:
+		notice = cli.username + " was disconnected for being idle"
+	case cli.kicked:
+		notice = cli.username + " was disconnected for not keeping up"
+	}
+	for r := range cli.rooms {
+		h.part(cli, r, notice)
+	}
+	delete(h.clients, cli.id)
+	delete(h.byName, nameKey(cli.username))
+}
+
+// join adds cli to the named room, creating it if needed, announces it to
+// the other members and shows cli who is there. The room becomes the one
+// cli's messages go to.
+func (h *hub) join(cli *client, name, notice string) {
+	r, ok := h.rooms[name]
+	if !ok {
+		r = &room{name: name, members: make(map[uint64]*client), history: newHistory(h.history)}
+		h.rooms[name] = r
+	}
+	if r.members[cli.id] == nil {
+		h.broadcast(r, notice)
+		r.members[cli.id] = cli
+		cli.rooms[r] = true
+	}
+	cli.current = r
+
+	cli.send(" ") // formatting line
+	cli.send("Now talking in " + name)
+	if len(r.members) > 1 {
+		cli.send("Currently in " + name + ": ")
+		for _, user := range r.usernames() {
+			if user != cli.username {
+				cli.send(" 	" + user)
+			}
+		}
+	}
+	h.replay(cli, r, h.history.replay)
+	cli.send(" ") // formatting line
+}
+
+// part removes cli from r, announcing it to those left, and drops the room
+// once it is empty. If r was cli's current room, the current room becomes
+// another one cli is in, if any.
+func (h *hub) part(cli *client, r *room, notice string) {
+	delete(r.members, cli.id)
+	delete(cli.rooms, r)
+	if len(r.members) == 0 {
+		delete(h.rooms, r.name)
+	}
+	h.broadcast(r, notice)
+
+	if cli.current == r {
+		cli.current = nil
+		for other := range cli.rooms {
+			if cli.current == nil || other.name < cli.current.name {
+				cli.current = other
+			}
+		}
+	}
+}
+
+// rename changes cli's username and tells everyone in its rooms. Changing
+// only the case of one's own name is allowed.
+func (h *hub) rename(cli *client, name string) error {
+	if err := h.checkName(cli, name); err != nil {
+		return err
+	}
+	old := cli.username
+	delete(h.byName, nameKey(old))
+	cli.username = name
+	h.byName[nameKey(name)] = cli
+
+	notice := old + " is now known as " + name
+	told := map[uint64]bool{cli.id: true}
+	cli.send(notice)
+	for r := range cli.rooms {
+		for id, member := range r.members {
+			if !told[id] {
+				told[id] = true
+				member.send(notice)
+			}
+		}
+	}
+	return nil
+}
+
+// broadcast sends msg to every member of r
+func (h *hub) broadcast(r *room, msg string) {
+	for _, cli := range r.members {
+		cli.send(msg)
+	}
+}
+
+// say sends a line typed by cli: commands are run, anything else goes to
+// cli's current room
+func (h *hub) say(cli *client, line string) {
+	if strings.HasPrefix(line, "/") {
+		h.dispatch(cli, line)
+		return
+	}
+	if cli.current == nil {
+		cli.send("You are not in any room; /join #room first")
+		return
+	}
+	h.post(cli.current, cli, line, false)
+}
+
+// direct delivers a private message to one client and echoes it back to
+// the sender
+func (h *hub) direct(from, to *client, text string) {
+	to.send("(private) " + from.username + ": " + text)
+	if to != from {
+		from.send("(to " + to.username + ") " + from.username + ": " + text)
+	}
+}
+
+// usernames returns the room's members' names in order
+func (r *room) usernames() []string {
+	names := make([]string, 0, len(r.members))
+	for _, cli := range r.members {
+		names = append(names, cli.username)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestHub returns a hub with the named clients in it. Their queues are
+// large enough that nothing is dropped, and no writers drain them, so
+// tests read what each was sent with queued.
+func newTestHub(usernames ...string) (*hub, []*client) {
+	h := newHub(historyConfig{})
+	var clients []*client
+	for _, name := range usernames {
+		cli := newClient("", nil, 256, dropNewest)
+		if err := h.enter(cli, name); err != nil {
+			panic(err)
+		}
+		clients = append(clients, cli)
+	}
+	for _, cli := range clients {
+		queued(cli)
+	}
+	return h, clients
+}
+
+// got reports whether cli was sent a line containing want, and empties
+// its queue
+func got(cli *client, want string) bool {
+	return slices.ContainsFunc(queued(cli), func(msg string) bool {
+		return strings.Contains(msg, want)
+	})
+}
+
+func TestRoomBroadcast(t *testing.T) {
+	h, c := newTestHub("alice", "bob", "carol")
+	alice, bob, carol := c[0], c[1], c[2]
+
+	h.say(alice, "/join #go")
+	if !got(alice, "Now talking in #go") {
+		t.Error("alice was not told about joining #go")
+	}
+	h.say(bob, "/join #go")
+	if !got(alice, "bob has joined #go") || got(carol, "bob has joined") {
+		t.Error("join notice should reach #go members only")
+	}
+	queued(bob)
+
+	h.say(alice, "hello")
+	if !got(bob, "#go alice: hello") || got(carol, "hello") {
+		t.Error("message should reach #go members only")
+	}
+
+	// A client stays in its other rooms
+	h.say(carol, "lobby talk")
+	if !got(alice, "#lobby carol: lobby talk") {
+		t.Error("alice stopped hearing #lobby")
+	}
+}
+
+func TestRoomLifecycle(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	alice, bob := c[0], c[1]
+
+	h.say(alice, "/join #Go")
+	if h.rooms["#go"] == nil {
+		t.Fatal("#go was not created")
+	}
+	h.say(bob, "/join #go")
+	queued(bob)
+
+	h.say(alice, "/leave")
+	if !got(bob, "alice has left #go") {
+		t.Error("bob was not told alice left")
+	}
+	if !got(alice, "Now talking in #lobby") || alice.current.name != lobby {
+		t.Error("alice should be back in #lobby")
+	}
+	h.say(bob, "/leave #go")
+	if h.rooms["#go"] != nil {
+		t.Error("empty room was not removed")
+	}
+
+	h.leave(bob)
+	if !got(alice, "bob has left") {
+		t.Error("alice was not told bob left")
+	}
+	h.say(alice, "/leave")
+	h.say(alice, "anyone?")
+	if !got(alice, "not in any room") || len(h.rooms) != 0 {
+		t.Errorf("rooms left: %v", sortedKeys(h.rooms))
+	}
+}
+
+func TestRoomsAndWho(t *testing.T) {
+	h, c := newTestHub("alice", "bob")
+	alice, bob := c[0], c[1]
+	h.say(bob, "/join #go")
+
+	h.say(alice, "/rooms")
+	msgs := queued(alice)
+	if !slices.Contains(msgs, " 	#go (1)") || !slices.Contains(msgs, " 	#lobby (2)") {
+		t.Errorf("/rooms = %q", msgs)
+	}
+
+	h.say(alice, "/who #go")
+	msgs = queued(alice)
+	if !slices.Contains(msgs, " 	bob") || slices.Contains(msgs, " 	alice") {
+		t.Errorf("/who #go = %q", msgs)
+	}
+
+	h.say(alice, "/who #nope")
+	if !got(alice, "no such room") {
+		t.Error("/who of a missing room did not fail")
+	}
+}
+
+func TestJoinValidates(t *testing.T) {
+	h, c := newTestHub("alice")
+	alice := c[0]
+	for _, cmd := range []string{"/join", "/join go", "/join #a b", "/join #" + strings.Repeat("x", maxRoomName), "/join #sp@ce"} {
+		h.say(alice, cmd)
+		if len(h.rooms) != 1 || alice.current.name != lobby {
+			t.Errorf("%q changed rooms", cmd)
+		}
+		queued(alice)
+	}
+}
+
+func TestDirectMessage(t *testing.T) {
+	h, c := newTestHub("alice", "bob", "carol")
+	alice, bob, carol := c[0], c[1], c[2]
+
+	h.say(alice, "/msg bob  psst,  over here")
+	if !got(bob, "(private) alice: psst,  over here") {
+		t.Error("bob did not get the message as typed")
+	}
+	if !got(alice, "(to bob) alice: psst,  over here") {
+		t.Error("alice did not get the echo")
+	}
+	if got(carol, "psst") {
+		t.Error("carol saw a private message")
+	}
+
+	h.say(alice, "/msg dave hi")
+	if !got(alice, "dave is not online") {
+		t.Error("no error for an unknown user")
+	}
+	h.leave(bob)
+	queued(alice)
+	h.say(alice, "/msg bob hi")
+	if !got(alice, "bob is not online") {
+		t.Error("no error for a user who left")
+	}
+	h.say(alice, "/msg bob")
+	if !got(alice, "Usage: /msg") {
+		t.Error("no usage for /msg without text")
+	}
+}
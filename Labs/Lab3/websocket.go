@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// httpHandler serves the browser client at / and its WebSocket at /ws
+func (s *server) httpHandler() http.Handler {
+	upgrader := websocket.Upgrader{} // same-origin only, which suits the bundled client
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFileFS(w, r, webFiles, "web/index.html")
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade has already replied with an error
+		}
+		s.handleConn(newWSConn(ws))
+	})
+	return mux
+}
+
+// wsConn lets a WebSocket stand in for a TCP connection, so browser
+// clients go through handleConn like everyone else. Each text message
+// received reads as one line, and each line written is sent as one
+// message.
+type wsConn struct {
+	ws *websocket.Conn
+	r  io.Reader // rest of the message being read, nil between messages
+
+	wmu sync.Mutex // the client writer and kick may write at once
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	return &wsConn{ws: ws}
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.r == nil {
+			_, r, err := c.ws.NextReader()
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return 0, io.EOF
+			}
+			if err != nil {
+				return 0, err
+			}
+			c.r = io.MultiReader(r, strings.NewReader("\n"))
+		}
+		n, err := c.r.Read(p)
+		if err == io.EOF {
+			c.r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.ws.WriteMessage(websocket.TextMessage, bytes.TrimSuffix(p, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close says goodbye with a close frame, if the peer is still listening,
+// and closes the connection
+func (c *wsConn) Close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr  { return c.ws.LocalAddr() }
+func (c *wsConn) RemoteAddr() net.Addr { return c.ws.RemoteAddr() }
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	return errors.Join(c.SetReadDeadline(t), c.SetWriteDeadline(t))
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline also applies to a write already in progress, as it
+// does on a TCP connection. The WebSocket only uses its deadline for
+// writes that start later.
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	c.ws.SetWriteDeadline(t)
+	return c.ws.UnderlyingConn().SetWriteDeadline(t)
+}
+
+// serveHTTP serves browser clients on l until it fails
+func (s *server) serveHTTP(l net.Listener) error {
+	srv := &http.Server{Handler: s.httpHandler(), ReadHeaderTimeout: 10 * time.Second}
+	err := srv.Serve(l)
+	log.Printf("http: %v", err)
+	return err
+}
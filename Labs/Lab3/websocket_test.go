@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialWS connects a browser-like client to the server's HTTP handler
+func dialWS(t *testing.T, s *server) *websocket.Conn {
+	t.Helper()
+	hs := httptest.NewServer(s.httpHandler())
+	t.Cleanup(hs.Close)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(hs.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// expectWS reads messages until one contains want
+func expectWS(t *testing.T, ws *websocket.Conn, want string) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", want, err)
+		}
+		if strings.Contains(string(msg), want) {
+			return
+		}
+	}
+}
+
+func TestWebSocketSharesRooms(t *testing.T) {
+	s := startServer(16, dropNewest)
+	tcp := join(t, s, "tcpuser")
+
+	ws := dialWS(t, s)
+	expectWS(t, ws, "Choose username")
+	ws.WriteMessage(websocket.TextMessage, []byte("webuser"))
+	expectWS(t, ws, "Now talking in #lobby")
+	tcp.expect(t, "webuser has arrived")
+
+	ws.WriteMessage(websocket.TextMessage, []byte("hi from the browser"))
+	tcp.expect(t, "#lobby webuser: hi from the browser")
+
+	tcp.conn.Write([]byte("hi from the terminal\n"))
+	expectWS(t, ws, "#lobby tcpuser: hi from the terminal")
+
+	// Closing the browser tab looks like any other departure
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+	ws.Close()
+	tcp.expect(t, "webuser has left")
+}
+
+func TestWebSocketQuit(t *testing.T) {
+	s := startServer(16, dropNewest)
+	ws := dialWS(t, s)
+	expectWS(t, ws, "Choose username")
+	ws.WriteMessage(websocket.TextMessage, []byte("webuser"))
+	ws.WriteMessage(websocket.TextMessage, []byte("/quit"))
+	expectWS(t, ws, "Goodbye")
+
+	_, _, err := ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("after /quit: %v, want a normal close", err)
+	}
+}
+
+func TestServesClientPage(t *testing.T) {
+	hs := httptest.NewServer(startServer(1, dropNewest).httpHandler())
+	defer hs.Close()
+	resp, err := http.Get(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "new WebSocket") {
+		t.Errorf("GET / = %d, %.80q", resp.StatusCode, body)
+	}
+	resp, err = http.Get(hs.URL + "/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /nope = %d", resp.StatusCode)
+	}
+}